@@ -0,0 +1,160 @@
+package oneweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	BatchPath    = "/_batch"
+	maxBatchSize = 100
+)
+
+// BatchRequest is one sub-request in the JSON array POSTed to /_batch.  Path may include a query string
+type BatchRequest struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// BatchResult is the response to a BatchRequest.  Body holds JSON responses as is and anything else as a string
+type BatchResult struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+}
+
+// serveBatch dispatches each sub-request through the routing pipeline with the outer request's headers,
+// so sub-requests are authenticated as the same user
+func (c *ControllerRoutingHandler) serveBatch(rw http.ResponseWriter, r *http.Request) {
+	requestID := getRequestID(r.Header.Get(RequestIDHeader))
+	rw.Header().Set(RequestIDHeader, requestID)
+	if r.Method != "POST" {
+		writeError(rw, http.StatusMethodNotAllowed, errors.New("Batches must be POSTed"), requestID)
+		return
+	}
+	var requests []BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+		writeError(rw, http.StatusBadRequest, errors.Wrap(err, "Failed to read batch"), requestID)
+		return
+	}
+	if len(requests) > maxBatchSize {
+		writeError(rw, http.StatusRequestEntityTooLarge, errors.Errorf("Batches are limited to %d requests", maxBatchSize), requestID)
+		return
+	}
+
+	results := make([]BatchResult, len(requests))
+	runParallel(len(requests), c.batchParallel(), func(i int) {
+		results[i] = c.callBatchRequest(r, requests[i], requestID)
+	})
+
+	output, _ := json.Marshal(results)
+	writeResponse(rw, string(output))
+}
+
+func (c *ControllerRoutingHandler) batchParallel() int {
+	if c.BatchParallel <= 0 {
+		return 1
+	}
+	return c.BatchParallel
+}
+
+// callBatchRequest runs the sub-request with the batch's request ID so that its log entries can be correlated.
+// A panic, which the router has already logged, is answered with a 500 instead of crashing the server
+func (c *ControllerRoutingHandler) callBatchRequest(outer *http.Request, request BatchRequest, requestID string) (result BatchResult) {
+	defer func() {
+		if p := recover(); p != nil {
+			rw := newBatchResponseWriter()
+			rw.Header().Set(RequestIDHeader, requestID)
+			writeError(rw, http.StatusInternalServerError, errors.New("Panic calling controller method"), requestID)
+			result = rw.result()
+		}
+	}()
+	rw := newBatchResponseWriter()
+	r, err := newBatchSubRequest(outer, request, requestID)
+	if err == nil {
+		err = c.checkBatchRoute(r)
+	}
+	if err != nil {
+		writeError(rw, http.StatusBadRequest, err, requestID)
+	} else {
+		c.controllerRoutingHandler(rw, r)
+	}
+	return rw.result()
+}
+
+// checkBatchRoute rejects streaming, event and WebSocket routes, which can't answer inside a JSON array
+func (c *ControllerRoutingHandler) checkBatchRoute(r *http.Request) error {
+	key := getRouteKey(r.Method, newControllerRequest(r, c.naming()))
+	if rt := c.getRoute(key); rt != nil && rt.isStreaming() {
+		return errors.Errorf("Method \"%s\" can't be batched since it streams its response", rt.methodName)
+	}
+	return nil
+}
+
+func newBatchSubRequest(outer *http.Request, request BatchRequest, requestID string) (*http.Request, error) {
+	if !strings.HasPrefix(request.Path, "/") || strings.HasPrefix(request.Path, BatchPath) {
+		return nil, errors.Errorf("Invalid batch request path \"%s\"", request.Path)
+	}
+	r, err := http.NewRequest(strings.ToUpper(request.Method), request.Path, bytes.NewReader(request.Body))
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid batch request")
+	}
+	r = r.WithContext(outer.Context())
+	for name, values := range outer.Header {
+		if name != "Content-Length" && name != "Accept-Encoding" && name != IdempotencyKeyHeader {
+			r.Header[name] = values
+		}
+	}
+	r.Header.Set(RequestIDHeader, requestID)
+	r.RemoteAddr = outer.RemoteAddr
+	r.Host = outer.Host
+	return r, nil
+}
+
+// batchResponseWriter collects a sub-request's response in memory
+type batchResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBatchResponseWriter() *batchResponseWriter {
+	return &batchResponseWriter{header: make(http.Header)}
+}
+
+func (w *batchResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *batchResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *batchResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+func (w *batchResponseWriter) result() BatchResult {
+	result := BatchResult{Status: w.status, Headers: make(map[string]string, len(w.header))}
+	if result.Status == 0 {
+		result.Status = http.StatusOK
+	}
+	for name, values := range w.header {
+		result.Headers[name] = strings.Join(values, ", ")
+	}
+	body := bytes.TrimSpace(w.body.Bytes())
+	if strings.HasPrefix(w.header.Get("Content-Type"), "application/json") && json.Valid(body) {
+		result.Body = body
+	} else {
+		result.Body, _ = json.Marshal(w.body.String())
+	}
+	return result
+}
@@ -0,0 +1,135 @@
+package oneweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveBatch(router *ControllerRoutingHandler, method, body string) *httptest.ResponseRecorder {
+	r := newHttpRequest(method, BatchPath, ioutil.NopCloser(bytes.NewBufferString(body)))
+	r.Header.Set("X-User", `{"UserID":7}`)
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, r)
+	return rw
+}
+
+func getBatchRouter() *ControllerRoutingHandler {
+	router, _ := getCountingRouter()
+	router.ResponseCache = nil
+	router.RegisterController("projects", &MockController{})
+	router.Batch = true
+	return router
+}
+
+func TestBatch(t *testing.T) {
+	rw := serveBatch(getBatchRouter(), "POST", `[
+		{"method":"GET","path":"/counts/1/report"},
+		{"method":"get","path":"/projects/123/method?a=1"},
+		{"method":"PUT","path":"/projects/1","body":{"Hello":"batch"}},
+		{"method":"GET","path":"/projects/1/error"},
+		{"method":"GET","path":"/_batch"}
+	]`)
+	var results []BatchResult
+	if err := json.Unmarshal(rw.Body.Bytes(), &results); err != nil || rw.Code != 200 || len(results) != 5 {
+		t.Fatal("expected a result per sub-request", err, rw.Code, rw.Body.String())
+	}
+	if results[0].Status != 200 || string(results[0].Body) != `{"calls":1,"user":7}` || results[0].Headers["Content-Type"] != "application/json" {
+		t.Error("expected JSON body from the outer request's user", results[0])
+	}
+	if string(results[1].Body) != `"called GetMethod"` || string(results[2].Body) != `"Called Put with value batch"` {
+		t.Error("expected non-JSON bodies as strings", string(results[1].Body), string(results[2].Body))
+	}
+	if results[3].Status != 500 || !strings.Contains(string(results[3].Body), "failed") {
+		t.Error("expected error result", results[3])
+	}
+	if results[4].Status != 400 || !strings.Contains(string(results[4].Body), `Invalid batch request path \"/_batch\"`) {
+		t.Error("expected nested batch to be rejected", results[4])
+	}
+}
+
+func TestBatchParallel(t *testing.T) {
+	router := getBatchRouter()
+	router.BatchParallel = 4
+	requests := make([]BatchRequest, 20)
+	for i := range requests {
+		requests[i] = BatchRequest{Method: "GET", Path: "/projects/123/method"}
+	}
+	requests[19].Path = "/projects"
+	body, _ := json.Marshal(requests)
+	var results []BatchResult
+	json.Unmarshal(serveBatch(router, "POST", string(body)).Body.Bytes(), &results)
+	if len(results) != 20 || string(results[0].Body) != `"called GetMethod"` || string(results[19].Body) != `"called Index"` {
+		t.Fatal("expected results in request order", results)
+	}
+}
+
+func TestBatchErrors(t *testing.T) {
+	router := getBatchRouter()
+	if rw := serveBatch(router, "GET", ""); rw.Code != 405 {
+		t.Error("expected batches to require POST", rw.Code)
+	}
+	if rw := serveBatch(router, "POST", `{}`); rw.Code != 400 || !strings.HasPrefix(getErrorMessage(rw), "Failed to read batch") {
+		t.Error("expected bad request for a non-array batch", rw.Code, rw.Body.String())
+	}
+	router.Batch = false
+	if rw := serveBatch(router, "POST", `[]`); rw.Code != 500 || getErrorMessage(rw) != "Method \"Post\" not found" {
+		t.Error("expected /_batch to be routed normally when batches are disabled", rw.Code, rw.Body.String())
+	}
+}
+
+func TestBatchRejectsStreamingRoutes(t *testing.T) {
+	router := getBatchRouter()
+	router.RegisterController("events", &EventController{})
+	router.RegisterController("sockets", &SocketController{})
+	r := newHttpRequest("POST", BatchPath, ioutil.NopCloser(strings.NewReader(`[
+		{"method":"GET","path":"/events/1/forever"},
+		{"method":"GET","path":"/sockets/1/echo"},
+		{"method":"GET","path":"/projects/1/error"},
+		{"method":"GET","path":"nested"}
+	]`)))
+	r.Header.Set(RequestIDHeader, "batch-1234")
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, r)
+	var results []BatchResult
+	if err := json.Unmarshal(rw.Body.Bytes(), &results); err != nil || len(results) != 4 {
+		t.Fatal("expected a result per sub-request", err, rw.Body.String())
+	}
+	if results[0].Status != 400 || !strings.Contains(string(results[0].Body), `Method \"GetForever\" can't be batched`) || results[1].Status != 400 {
+		t.Error("expected streaming routes to be rejected", results[0], results[1])
+	}
+	for _, result := range results {
+		if !strings.Contains(string(result.Body), `"requestId":"batch-1234"`) {
+			t.Error("expected sub-requests to share the batch's request ID", result)
+		}
+	}
+	if results[2].Headers[RequestIDHeader] != "batch-1234" {
+		t.Error("expected routed sub-request to log the batch's request ID", results[2])
+	}
+}
+
+func TestBatchRecoversPanics(t *testing.T) {
+	router := getBatchRouter()
+	router.Logger = &fuzzLogger{}
+	router.RegisterController("panics", &PanicController{})
+	r := newHttpRequest("POST", BatchPath, ioutil.NopCloser(strings.NewReader(`[
+		{"method":"GET","path":"/panics/1"},
+		{"method":"GET","path":"/projects/123/method"}
+	]`)))
+	r.Header.Set(RequestIDHeader, "batch-1234")
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, r)
+	var results []BatchResult
+	if err := json.Unmarshal(rw.Body.Bytes(), &results); err != nil || rw.Code != 200 || len(results) != 2 {
+		t.Fatal("expected the batch to survive a panicking sub-request", err, rw.Code, rw.Body.String())
+	}
+	if results[0].Status != 500 || results[0].Headers[RequestIDHeader] != "batch-1234" || !strings.Contains(string(results[0].Body), `"requestId":"batch-1234"`) {
+		t.Error("expected panic to be answered with a 500 carrying the request ID", results[0])
+	}
+	if string(results[1].Body) != `"called GetMethod"` {
+		t.Error("expected other sub-requests to be answered", results[1])
+	}
+}
@@ -0,0 +1,207 @@
+package oneweb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Encoder wraps w in a compressing writer.  If the writer also has a Flush() error method it is
+// called when the response is flushed, so streamed responses still reach the client promptly
+type Encoder func(w io.Writer) io.WriteCloser
+
+// Compression negotiates a content-coding from Accept-Encoding and compresses responses of at least MinSize bytes
+type Compression struct {
+	MinSize  int
+	encoders map[string]Encoder
+	names    []string // registration order breaks ties between equally weighted encodings
+}
+
+// NewCompression creates a Compression with gzip and deflate registered
+func NewCompression(minSize int) *Compression {
+	c := &Compression{MinSize: minSize, encoders: make(map[string]Encoder)}
+	c.Register("gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) })
+	c.Register("deflate", func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }) // HTTP's deflate is the zlib format
+	return c
+}
+
+// Register adds or replaces the encoder for a content-coding such as br
+func (c *Compression) Register(name string, encoder Encoder) {
+	name = strings.ToLower(name)
+	if _, ok := c.encoders[name]; !ok {
+		c.names = append(c.names, name)
+	}
+	c.encoders[name] = encoder
+}
+
+// negotiate returns the registered encoding with the highest q-value in acceptEncoding, or "" for none
+func (c *Compression) negotiate(acceptEncoding string) string {
+	weights := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if value, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = value
+				}
+			}
+		}
+		if name == "*" {
+			wildcard = q
+		} else if name != "" {
+			weights[name] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, name := range c.names {
+		q, ok := weights[name]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the start of the response until it knows whether the response is big enough to compress
+type compressWriter struct {
+	http.ResponseWriter
+	compression *Compression
+	encoding    string
+	status      int
+	buf         []byte
+	decided     bool
+	encoder     io.WriteCloser
+}
+
+func (c *ControllerRoutingHandler) compressWriter(rw http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	if c.Compression == nil {
+		return rw
+	}
+	rw.Header().Add("Vary", "Accept-Encoding")
+	encoding := c.Compression.negotiate(r.Header.Get("Accept-Encoding"))
+	if encoding == "" || r.Method == "HEAD" {
+		return rw
+	}
+	return &compressWriter{ResponseWriter: rw, compression: c.Compression, encoding: encoding}
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	w.status = status
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		w.decide(false)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.compression.MinSize {
+			return len(p), nil
+		}
+		return len(p), w.decide(true)
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// decide commits to compressing (if compress is set and nothing rules it out) or to sending the response as is
+func (w *compressWriter) decide(compress bool) error {
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	if compress && header.Get("Content-Encoding") == "" {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		setEncodedETag(header, w.encoding)
+		w.encoder = w.compression.encoders[w.encoding](w.ResponseWriter)
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// writeNotModified sends a 304 in place of a body of size bytes.  The 304 carries the ETag of the response it
+// stands in for, which only has the content-coding suffix if a body that size would have been compressed
+func writeNotModified(rw http.ResponseWriter, size int) {
+	if w, ok := rw.(*compressWriter); ok && size >= w.compression.MinSize && w.Header().Get("Content-Encoding") == "" {
+		setEncodedETag(w.Header(), w.encoding)
+	}
+	rw.WriteHeader(http.StatusNotModified)
+}
+
+// setEncodedETag makes a strong ETag specific to the content-coding, since RFC 9110 requires each
+// representation to have its own strong validator.  etagListMatches strips the suffix again
+func setEncodedETag(header http.Header, encoding string) {
+	if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) && strings.HasSuffix(etag, `"`) && len(etag) > 1 {
+		header.Set("ETag", etag[:len(etag)-1]+"-"+encoding+`"`)
+	}
+}
+
+// Flush commits to compression since flushed responses are usually streams which will outgrow MinSize
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close writes any buffered response and finishes the compressed stream
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("ResponseWriter does not support hijacking")
+}
+
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func closeCompressWriter(rw http.ResponseWriter) {
+	if w, ok := rw.(*compressWriter); ok {
+		w.Close()
+	}
+}
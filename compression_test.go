@@ -0,0 +1,176 @@
+package oneweb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type CompressionController struct {
+}
+
+func (c *CompressionController) Index(cr *ControllerRequest) (string, error) {
+	return `[` + strings.Repeat(`{"name":"item"},`, 100) + `{}]`, nil
+}
+
+func (c *CompressionController) GetSmall(cr *ControllerRequest) (string, error) {
+	return `{}`, nil
+}
+
+func (c *CompressionController) GetRaw(cr *ControllerRequest, rw http.ResponseWriter, r *http.Request) {
+	rw.WriteHeader(http.StatusCreated)
+	rw.Write([]byte(strings.Repeat("raw ", 100)))
+}
+
+func getCompressionRouter() *ControllerRoutingHandler {
+	router := getMockRouter()
+	router.Compression = NewCompression(256)
+	router.RegisterController("items", &CompressionController{})
+	return router
+}
+
+func serveCompressed(router *ControllerRoutingHandler, url, acceptEncoding string) *httptest.ResponseRecorder {
+	r := newHttpRequest("GET", url, nil)
+	r.Header.Set("Accept-Encoding", acceptEncoding)
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, r)
+	return rw
+}
+
+func TestCompressionGzip(t *testing.T) {
+	rw := serveCompressed(getCompressionRouter(), "/items", "gzip, deflate")
+	if rw.Header().Get("Content-Encoding") != "gzip" || rw.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatal("expected gzip response", rw.Header())
+	}
+	reader, err := gzip.NewReader(rw.Body)
+	if err != nil {
+		t.Fatal("expected gzip body", err)
+	}
+	body, _ := ioutil.ReadAll(reader)
+	expected, _ := (&CompressionController{}).Index(nil)
+	if string(body) != expected {
+		t.Fatal("expected decompressed body to match", string(body))
+	}
+}
+
+func TestCompressionDeflatePreferred(t *testing.T) {
+	rw := serveCompressed(getCompressionRouter(), "/items", "gzip;q=0.5, deflate")
+	reader, err := zlib.NewReader(rw.Body)
+	if rw.Header().Get("Content-Encoding") != "deflate" || err != nil {
+		t.Fatal("expected deflate response", rw.Header(), err)
+	}
+	if body, _ := ioutil.ReadAll(reader); !strings.HasPrefix(string(body), `[{"name":"item"}`) {
+		t.Fatal("expected decompressed body", string(body))
+	}
+}
+
+func TestCompressionBelowMinSize(t *testing.T) {
+	rw := serveCompressed(getCompressionRouter(), "/items/1/small", "gzip")
+	if rw.Header().Get("Content-Encoding") != "" || rw.Body.String() != `{}` || rw.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatal("expected small response to be sent uncompressed", rw.Header(), rw.Body.String())
+	}
+}
+
+func TestCompressionNotAccepted(t *testing.T) {
+	for _, acceptEncoding := range []string{"", "identity", "gzip;q=0, br", "*;q=0"} {
+		if rw := serveCompressed(getCompressionRouter(), "/items", acceptEncoding); rw.Header().Get("Content-Encoding") != "" {
+			t.Error("expected uncompressed response for", acceptEncoding, rw.Header())
+		}
+	}
+}
+
+func TestCompressionRawMethod(t *testing.T) {
+	rw := serveCompressed(getCompressionRouter(), "/items/1/raw", "*")
+	reader, err := gzip.NewReader(rw.Body)
+	if rw.Code != http.StatusCreated || rw.Header().Get("Content-Encoding") != "gzip" || err != nil {
+		t.Fatal("expected compressed raw response with status", rw.Code, rw.Header(), err)
+	}
+	if body, _ := ioutil.ReadAll(reader); string(body) != strings.Repeat("raw ", 100) {
+		t.Fatal("expected raw body", string(body))
+	}
+}
+
+func TestCompressionNotModified(t *testing.T) {
+	router := getCompressionRouter()
+	etag := serveCompressed(router, "/items", "gzip").Header().Get("ETag")
+	r := newHttpRequest("GET", "/items", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("If-None-Match", etag)
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, r)
+	if rw.Code != http.StatusNotModified || rw.Body.Len() != 0 || rw.Header().Get("Content-Encoding") != "" || rw.Header().Get("ETag") != etag {
+		t.Fatal("expected empty 304", rw.Code, rw.Header())
+	}
+}
+
+func TestCompressionNotModifiedBelowMinSize(t *testing.T) {
+	router := getCompressionRouter()
+	etag := serveCompressed(router, "/items/1/small", "gzip").Header().Get("ETag")
+	if etag != computeETag(`{}`) {
+		t.Fatal("expected uncompressed response to keep its ETag", etag)
+	}
+	r := newHttpRequest("GET", "/items/1/small", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("If-None-Match", etag)
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, r)
+	if rw.Code != http.StatusNotModified || rw.Header().Get("ETag") != etag {
+		t.Fatal("expected 304 to carry the uncompressed response's ETag", rw.Code, rw.Header())
+	}
+}
+
+func TestCompressionETagIsEncodingSpecific(t *testing.T) {
+	router := getCompressionRouter()
+	gzipped := serveCompressed(router, "/items", "gzip").Header().Get("ETag")
+	identity := serveCompressed(router, "/items", "").Header().Get("ETag")
+	if gzipped == identity || gzipped != identity[:len(identity)-1]+`-gzip"` {
+		t.Fatal("expected encoding specific ETag", gzipped, identity)
+	}
+	r := newHttpRequest("GET", "/items", nil)
+	r.Header.Set("If-None-Match", gzipped)
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, r)
+	if rw.Code != http.StatusNotModified || rw.Header().Get("ETag") != identity {
+		t.Fatal("expected gzip ETag to validate the identity response", rw.Code, rw.Header())
+	}
+}
+
+func TestCompressionCustomEncoder(t *testing.T) {
+	compression := NewCompression(0)
+	compression.Register("BR", func(w io.Writer) io.WriteCloser { return &nopWriteCloser{w} })
+	if encoding := compression.negotiate("br, gzip"); encoding != "gzip" {
+		t.Error("expected registration order to break ties", encoding)
+	}
+	if encoding := compression.negotiate("br, gzip;q=0.9"); encoding != "br" {
+		t.Error("expected br", encoding)
+	}
+}
+
+func TestCompressWriterFlushCommitsToCompression(t *testing.T) {
+	rw := httptest.NewRecorder()
+	w := &compressWriter{ResponseWriter: rw, compression: NewCompression(1024), encoding: "gzip"}
+	w.Write([]byte("data: 1\n\n"))
+	w.Flush()
+	if rw.Header().Get("Content-Encoding") != "gzip" || !rw.Flushed || rw.Body.Len() == 0 {
+		t.Fatal("expected flush to send compressed data", rw.Header(), rw.Body.Len())
+	}
+	w.Close()
+	reader, _ := gzip.NewReader(bytes.NewReader(rw.Body.Bytes()))
+	if body, _ := ioutil.ReadAll(reader); string(body) != "data: 1\n\n" {
+		t.Fatal("expected complete gzip stream", string(body))
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (w *nopWriteCloser) Close() error {
+	return nil
+}
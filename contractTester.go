@@ -0,0 +1,237 @@
+package oneweb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ContractSpec is a simplified OpenAPI document.  Paths maps a URL template such as /projects/{id}/archive to its
+// operations by HTTP method (get, post, put, patch or delete).  Path segments in braces are parameters
+type ContractSpec struct {
+	Paths map[string]map[string]*ContractOperation `json:"paths"`
+}
+
+// ContractOperation declares what a route accepts and returns
+type ContractOperation struct {
+	RequestBody *Schema            `json:"requestBody,omitempty"` // a body matching the schema is sent with the request
+	Responses   map[string]*Schema `json:"responses"`             // status code or "default" to the shape of the body.  Any body if nil
+}
+
+// ContractReport lists routes as "METHOD /path".  Missing routes are declared by the spec but not served, and extra
+// routes are served but not declared
+type ContractReport struct {
+	MissingRoutes []string
+	ExtraRoutes   []string
+	Violations    []ContractViolation
+	Passed        []string
+}
+
+// ContractViolation is a response to a declared route which doesn't match the spec
+type ContractViolation struct {
+	Route        string
+	StatusCode   int
+	ResponseBody string
+	Err          error
+}
+
+func (r ContractReport) OK() bool {
+	return len(r.MissingRoutes) == 0 && len(r.ExtraRoutes) == 0 && len(r.Violations) == 0
+}
+
+func AutoContractTestRouter(t TestRunner, router *ControllerRoutingHandler, spec *ContractSpec) {
+	report := ContractTestRouter(router, spec)
+	for _, route := range report.MissingRoutes {
+		t.Error(fmt.Sprintf("%s is in the spec but not served", route))
+	}
+	for _, route := range report.ExtraRoutes {
+		t.Error(fmt.Sprintf("%s is served but not in the spec", route))
+	}
+	for _, violation := range report.Violations {
+		t.Error(fmt.Sprintf("%s returned %d: %v", violation.Route, violation.StatusCode, violation.Err))
+	}
+	for _, route := range report.Passed {
+		t.Logf("%s matches the spec", route)
+	}
+}
+
+// ContractTestRouter sends a request to every route in the spec which the router serves, with 1 for each path
+// parameter, and checks the status and body against the declared responses.  Requests go to the router's routes
+// without its ResponseCache, RateLimits, Idempotency and Recorder, so results don't depend on their state and
+// don't change it.  Paths which aren't valid URLs are reported as violations
+func ContractTestRouter(router *ControllerRoutingHandler, spec *ContractSpec) ContractReport {
+	var report ContractReport
+	router = router.withoutState()
+	served := make(map[*route]bool)
+	for _, path := range getSortedKeys(spec.Paths) {
+		operations := spec.Paths[path]
+		methods := make([]string, 0, len(operations))
+		for method := range operations {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			httpMethod := strings.ToUpper(method)
+			name := httpMethod + " " + path
+			url := getContractURL(path)
+			rt, err := router.getContractRoute(httpMethod, url)
+			if err != nil {
+				report.Violations = append(report.Violations, ContractViolation{Route: name, Err: err})
+				continue
+			}
+			if rt == nil {
+				report.MissingRoutes = append(report.MissingRoutes, name)
+				continue
+			}
+			served[rt] = true
+			if violation := sendContractRequest(router, httpMethod, url, operations[method]); violation != nil {
+				violation.Route = name
+				report.Violations = append(report.Violations, *violation)
+			} else {
+				report.Passed = append(report.Passed, name)
+			}
+		}
+	}
+	report.ExtraRoutes = router.getUndeclaredRoutes(served)
+	return report
+}
+
+func getSortedKeys(paths map[string]map[string]*ContractOperation) []string {
+	keys := make([]string, 0, len(paths))
+	for key := range paths {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func getContractURL(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			segments[i] = httpFuzzItemID
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// withoutState returns a handler serving the same routes with the same settings, but without the stores
+// which remember earlier requests.  Every exported field is copied so that new settings carry over.  The
+// unexported lock and table can't be copied by value, so the new handler gets its own
+func (c *ControllerRoutingHandler) withoutState() *ControllerRoutingHandler {
+	isolated := &ControllerRoutingHandler{}
+	from, to := reflect.ValueOf(c).Elem(), reflect.ValueOf(isolated).Elem()
+	for i := 0; i < from.NumField(); i++ {
+		if from.Type().Field(i).PkgPath == "" { // exported
+			to.Field(i).Set(from.Field(i))
+		}
+	}
+	isolated.ResponseCache, isolated.RateLimits, isolated.Idempotency, isolated.Recorder = nil, nil, nil, nil
+	isolated.table.Store(c.loadTable())
+	return isolated
+}
+
+// getContractRoute returns the route the router would call for the request, or nil if it would be rejected
+func (c *ControllerRoutingHandler) getContractRoute(httpMethod, url string) (*route, error) {
+	if !strings.HasPrefix(url, "/") {
+		return nil, fmt.Errorf("Invalid path: must start with /")
+	}
+	r, err := http.NewRequest(httpMethod, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid path: %v", err)
+	}
+	cr := newControllerRequest(r, c.naming())
+	key := getRouteKey(httpMethod, cr)
+	if checkUrl(httpMethod, key.verb, cr) != nil {
+		return nil, nil
+	}
+	return c.getRoute(key), nil
+}
+
+func (c *ControllerRoutingHandler) getUndeclaredRoutes(declared map[*route]bool) []string {
+	table := c.loadTable()
+	urlNames := make(map[string]string)
+	for name, key := range table.controllerKeys {
+		urlNames[key] = c.naming().URLName(name)
+	}
+	var undeclared []string
+	for key, rt := range table.routes {
+		if !declared[rt] {
+			httpMethod, url := getRouteRequest(urlNames[key.controller], rt, "{id}")
+			undeclared = append(undeclared, httpMethod+" "+url)
+		}
+	}
+	sort.Strings(undeclared)
+	return undeclared
+}
+
+func sendContractRequest(router *ControllerRoutingHandler, httpMethod, url string, operation *ContractOperation) (violation *ContractViolation) {
+	if operation == nil {
+		operation = &ContractOperation{}
+	}
+	var body string
+	if operation.RequestBody != nil {
+		sample, _ := json.Marshal(operation.RequestBody.sample())
+		body = string(sample)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), fuzzEventTimeout) // ends event streams
+	defer cancel()
+	r, _ := http.NewRequestWithContext(ctx, httpMethod, url, strings.NewReader(body)) // url was parsed by getContractRoute
+	r.Header.Set("X-User", `{"UserID":1}`)
+	rw := httptest.NewRecorder()
+	defer func() {
+		if p := recover(); p != nil {
+			violation = &ContractViolation{StatusCode: rw.Code, Err: fmt.Errorf("panicked: %v", p)}
+		}
+	}()
+	router.Handler().ServeHTTP(rw, r)
+
+	schema, ok := operation.Responses[strconv.Itoa(rw.Code)]
+	if !ok {
+		schema, ok = operation.Responses["default"]
+	}
+	var err error
+	switch {
+	case !ok:
+		err = fmt.Errorf("status %d is not declared", rw.Code)
+	case schema != nil:
+		err = schema.ValidateJSON(rw.Body.Bytes())
+	}
+	if err != nil {
+		return &ContractViolation{StatusCode: rw.Code, ResponseBody: rw.Body.String(), Err: err}
+	}
+	return nil
+}
+
+// sample returns the smallest value matching the schema
+func (s *Schema) sample() interface{} {
+	if s == nil {
+		return nil
+	}
+	switch s.Type {
+	case "string":
+		return "a"
+	case "number", "integer":
+		return 1
+	case "boolean":
+		return true
+	case "null":
+		return nil
+	case "array":
+		if s.Items == nil {
+			return []interface{}{}
+		}
+		return []interface{}{s.Items.sample()}
+	}
+	value := make(map[string]interface{})
+	for name, property := range s.Properties {
+		value[name] = property.sample()
+	}
+	return value
+}
@@ -0,0 +1,170 @@
+package oneweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type ContractProject struct {
+	Name string `json:"name"`
+}
+
+type ContractController struct {
+}
+
+func (c *ContractController) Index(cr *ControllerRequest) (string, error) {
+	return `[{"id":1,"name":"first"}]`, nil
+}
+
+func (c *ContractController) Get(cr *ControllerRequest) (string, error) {
+	return `{"id":"1","name":"first"}`, nil
+}
+
+func (c *ContractController) Post(cr *ControllerRequest, project *ContractProject) (string, error) {
+	return fmt.Sprintf(`{"id":2,"name":%q}`, project.Name), nil
+}
+
+func (c *ContractController) GetArchive(cr *ControllerRequest) (string, error) {
+	return `{}`, nil
+}
+
+func (c *ContractController) RateLimits() map[string]RateLimit {
+	return map[string]RateLimit{"Index": {Requests: 1, Period: time.Minute}}
+}
+
+func (c *ContractController) CachePolicies() map[string]CachePolicy {
+	return map[string]CachePolicy{"Get": {TTL: time.Minute}}
+}
+
+const contractSpecJSON = `{
+	"paths": {
+		"/projects": {
+			"get": {"responses": {"200": {"type": "array", "items": {"type": "object", "required": ["id"], "properties": {"id": {"type": "integer"}}}}}},
+			"post": {
+				"requestBody": {"type": "object", "properties": {"name": {"type": "string"}}},
+				"responses": {"200": {"type": "object", "required": ["id", "name"], "properties": {"name": {"type": "string"}}}}
+			}
+		},
+		"/projects/{id}": {
+			"get": {"responses": {"200": {"type": "object", "properties": {"id": {"type": "integer"}}}}},
+			"delete": {"responses": {"204": null}}
+		}
+	}
+}`
+
+func getContractSpec(t *testing.T) *ContractSpec {
+	spec := &ContractSpec{}
+	if err := json.Unmarshal([]byte(contractSpecJSON), spec); err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func getContractRouter() *ControllerRoutingHandler {
+	router := NewControllerRoutingHandler()
+	router.Logger = &fuzzLogger{}
+	router.RegisterController("projects", &ContractController{})
+	return router
+}
+
+func TestContractTestRouter(t *testing.T) {
+	report := ContractTestRouter(getContractRouter(), getContractSpec(t))
+	if report.OK() {
+		t.Fatal("expected contract to fail")
+	}
+	if len(report.MissingRoutes) != 1 || report.MissingRoutes[0] != "DELETE /projects/{id}" {
+		t.Error("expected missing delete route", report.MissingRoutes)
+	}
+	if len(report.ExtraRoutes) != 1 || report.ExtraRoutes[0] != "GET /projects/{id}/Archive" {
+		t.Error("expected extra archive route", report.ExtraRoutes)
+	}
+	if len(report.Violations) != 1 || report.Violations[0].Route != "GET /projects/{id}" || report.Violations[0].Err.Error() != "$.id: expected integer, got string" {
+		t.Fatal("expected Get to violate its response shape", report.Violations)
+	}
+	if len(report.Passed) != 2 || report.Passed[0] != "GET /projects" || report.Passed[1] != "POST /projects" {
+		t.Error("expected Index and Post to pass", report.Passed)
+	}
+}
+
+func TestContractTestRouterUndeclaredStatus(t *testing.T) {
+	spec := &ContractSpec{Paths: map[string]map[string]*ContractOperation{
+		"/projects": {"post": {RequestBody: &Schema{Type: "object"}, Responses: map[string]*Schema{"201": nil}}},
+	}}
+	report := ContractTestRouter(getContractRouter(), spec)
+	if len(report.Violations) != 1 || report.Violations[0].Err.Error() != "status 200 is not declared" {
+		t.Fatal("expected undeclared status", report.Violations)
+	}
+}
+
+func TestAutoContractTestRouter(t *testing.T) {
+	tester := &MockTestRunner{}
+	AutoContractTestRouter(tester, getContractRouter(), getContractSpec(t))
+	if len(tester.Errors) != 3 || len(tester.Messages) != 2 {
+		t.Error("expected 3 errors and 2 passing routes", tester.Errors, tester.Messages)
+	}
+}
+
+func TestSchemaSample(t *testing.T) {
+	sample, _ := json.Marshal(projectSchema.sample())
+	if err := projectSchema.ValidateJSON(sample); err != nil || string(sample) != `{"id":1,"name":"a","tags":["a"]}` {
+		t.Error("expected sample to match its schema", string(sample), err)
+	}
+}
+
+func TestContractTestRouterBadPath(t *testing.T) {
+	spec := &ContractSpec{Paths: map[string]map[string]*ContractOperation{
+		"projects/{id}": {"get": {Responses: map[string]*Schema{"200": nil}}},
+		"/projects/%zz": {"get": {Responses: map[string]*Schema{"200": nil}}},
+	}}
+	report := ContractTestRouter(getContractRouter(), spec)
+	if len(report.Violations) != 2 || report.Violations[0].Route != "GET /projects/%zz" || report.Violations[1].Err.Error() != "Invalid path: must start with /" {
+		t.Fatal("expected invalid paths to be reported", report.Violations)
+	}
+}
+
+func TestContractTestRouterDoesNotShareState(t *testing.T) {
+	router := getContractRouter()
+	router.ResponseCache = NewMemoryResponseCache(10)
+	router.RateLimits = NewMemoryRateLimitStore()
+	first := ContractTestRouter(router, getContractSpec(t))
+	second := ContractTestRouter(router, getContractSpec(t))
+	if len(first.Passed) != 2 || len(second.Passed) != 2 || len(second.Violations) != 1 {
+		t.Fatal("expected repeated runs to match", first, second)
+	}
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, newHttpRequest("GET", "/projects", nil))
+	if rw.Code != 200 {
+		t.Fatal("expected contract requests not to use up the rate limit", rw.Code)
+	}
+}
+
+func TestWithoutStateCopiesSettings(t *testing.T) {
+	router := getContractRouter()
+	router.ReuseRequests, router.Batch, router.BatchParallel, router.IdempotencyTTL = true, true, 4, time.Hour
+	router.ResponseCache = NewMemoryResponseCache(10)
+	router.RateLimits = NewMemoryRateLimitStore()
+	router.Recorder = NewTrafficRecorder(&bytes.Buffer{})
+	isolated := router.withoutState()
+	if isolated.ResponseCache != nil || isolated.RateLimits != nil || isolated.Idempotency != nil || isolated.Recorder != nil {
+		t.Fatal("expected stores to be left out", isolated)
+	}
+	from, to := reflect.ValueOf(router).Elem(), reflect.ValueOf(isolated).Elem()
+	for i := 0; i < from.NumField(); i++ {
+		field := from.Type().Field(i)
+		switch field.Name {
+		case "ResponseCache", "RateLimits", "Idempotency", "Recorder":
+		default:
+			if field.PkgPath == "" && !reflect.DeepEqual(from.Field(i).Interface(), to.Field(i).Interface()) {
+				t.Error("expected setting to be copied", field.Name)
+			}
+		}
+	}
+	if isolated.getRoute(routeKey{"Projects", "Index", ""}) == nil {
+		t.Error("expected routes to be shared")
+	}
+}
@@ -1,11 +1,24 @@
 package oneweb
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
+// fuzzEventTimeout bounds how long an event method is left running before its context is cancelled
+const fuzzEventTimeout = 100 * time.Millisecond
+
 type TestRunner interface {
 	Error(args ...interface{})
 	Logf(format string, args ...interface{})
@@ -16,57 +29,223 @@ type MethodTestResult struct {
 	ValidationError          error
 	HasInvalidSQLQueryParams bool
 	ReturnData               []interface{}
+	ValidJSON                bool  // the returned string parses as JSON.  Only checked when no error is returned
+	SchemaError              error // the returned JSON doesn't match the method's ResponseTypes entry
+	ErrorWithBody            bool  // the method returned an error along with a non-empty string
+	Input                    FuzzInput
+	HTTPMethod               string // request which the router maps to the method, empty if it rejects it.  See FuzzOptions.ControllerName
+	URL                      string
+	TimedOut                 bool // the method was still running after FuzzOptions.Timeout
+	Duration                 time.Duration
+	Panic                    interface{} // value the method panicked with, if it did
 }
 
 func fuzzTestControllerMethod(controller interface{}, methodName string) MethodTestResult {
-	return testControllerMethod(reflect.ValueOf(controller), methodName)
+	options := FuzzOptions{}
+	methods, _ := discoverRoutes(controller, options.controllerName(controller), options.naming())
+	for _, method := range methods {
+		if method.methodName == methodName {
+			return testControllerMethod(controller, options, method, options.combinations()[0])
+		}
+	}
+	return MethodTestResult{MethodName: methodName, ValidationError: fmt.Errorf("Method \"%s\" error: Not a route", methodName)}
 }
 
 func AutoFuzzTestController(t TestRunner, controller interface{}) {
-	results := FuzzTestController(controller)
+	AutoFuzzTestControllerWithOptions(t, controller, FuzzOptions{})
+}
+
+func AutoFuzzTestControllerWithOptions(t TestRunner, controller interface{}, options FuzzOptions) {
+	results := FuzzTestControllerWithOptions(controller, options)
 	for _, method := range results {
 		if method.ValidationError != nil {
 			t.Error(method.ValidationError)
 		}
-		t.Logf("Method \"%v\" returned: %v", method.MethodName, method.ReturnData)
+		if method.SchemaError != nil {
+			t.Error(fmt.Sprintf("Method \"%v\" returned an unexpected response: %v", method.MethodName, method.SchemaError))
+		}
+		if method.Panic != nil {
+			t.Error(fmt.Sprintf("Method \"%v\" panicked: %v (%v)", method.MethodName, method.Panic, method.Input))
+		}
+		if method.TimedOut {
+			t.Error(fmt.Sprintf("Method \"%v\" timed out after %v (%v)", method.MethodName, method.Duration, method.Input))
+		}
+		t.Logf("Method \"%v\" returned: %v (%v)", method.MethodName, method.ReturnData, method.Input)
 	}
 }
 
 func FuzzTestController(controller interface{}) []MethodTestResult {
-	controllerValue := reflect.ValueOf(controller)
-	numMethod := controllerValue.NumMethod()
-	testResults := make([]MethodTestResult, numMethod, numMethod)
-	for i := 0; i < numMethod; i++ {
-		testResults[i] = testControllerMethod(controllerValue, controllerValue.Type().Method(i).Name)
-	}
+	return FuzzTestControllerWithOptions(controller, FuzzOptions{})
+}
+
+// FuzzTestControllerWithOptions calls every route the router would serve once per combination of options,
+// options.Parallel calls at a time.  Methods the router would reject are reported once with a ValidationError.
+// Results are grouped by method in the same order however many run at once
+func FuzzTestControllerWithOptions(controller interface{}, options FuzzOptions) []MethodTestResult {
+	methods, _ := discoverRoutes(controller, options.controllerName(controller), options.naming())
+	calls := getFuzzCalls(methods, options.combinations())
+	testResults := make([]MethodTestResult, len(calls))
+	runParallel(len(calls), options.parallel(), func(i int) {
+		testResults[i] = testControllerMethod(controller, options, calls[i].method, calls[i].input)
+	})
 	return testResults
 }
 
-func testControllerMethod(controllerValue reflect.Value, methodName string) MethodTestResult {
-	var retVal []reflect.Value
-	_, _, err := validateMethod(controllerValue.MethodByName(methodName), methodName)
-	if err == nil {
-		retVal = callMethod(controllerValue.MethodByName(methodName))
+type fuzzCall struct {
+	method discoveredMethod
+	input  FuzzInput
+}
+
+// getFuzzCalls lists a call per route and input.  Methods the router rejects are listed once, and a route's
+// errMsg is only kept for its first input so that each problem is reported once
+func getFuzzCalls(methods []discoveredMethod, inputs []FuzzInput) []fuzzCall {
+	var calls []fuzzCall
+	for _, method := range methods {
+		if method.route == nil {
+			calls = append(calls, fuzzCall{method, inputs[0]})
+			continue
+		}
+		for i, input := range inputs {
+			if i == 1 {
+				method.errMsg = ""
+			}
+			calls = append(calls, fuzzCall{method, input})
+		}
 	}
-	return MethodTestResult{
-		MethodName:      methodName,
-		ValidationError: err,
-		ReturnData:      getReturnValues(retVal),
+	return calls
+}
+
+// testControllerMethod stops waiting for the method after options.Timeout, if set, and cancels cr.Context().
+// A method which ignores its context keeps running in the background
+func testControllerMethod(controller interface{}, options FuzzOptions, method discoveredMethod, input FuzzInput) MethodTestResult {
+	result := MethodTestResult{MethodName: method.methodName, Input: input}
+	if method.route == nil {
+		result.ValidationError = errors.New(strings.TrimSuffix(method.errMsg, "\n"))
+		return result
+	}
+	if method.errMsg != "" { // options the router couldn't apply, though it still serves the route
+		result.ValidationError = errors.New(strings.TrimSuffix(method.errMsg, "\n"))
+	}
+	itemID := input.ItemID
+	if itemID == "" {
+		itemID = httpFuzzItemID
 	}
+	result.HTTPMethod, result.URL = getRouteRequest(options.controllerName(controller), method.route, itemID)
+	if input.ActionFilter != "" && method.route.urlAction != "" {
+		result.URL += "/" + input.ActionFilter
+	}
+
+	var call methodCall
+	start := time.Now()
+	cr := input.newControllerRequest()
+	if options.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), options.Timeout)
+		defer cancel()
+		cr.ctx = ctx
+		done := make(chan methodCall, 1)
+		go func() {
+			done <- recoverMethod(method.route.Value, cr, newFuzzRequest(cr, result.HTTPMethod, result.URL))
+		}()
+		select {
+		case call = <-done:
+		case <-ctx.Done():
+			result.TimedOut = true
+		}
+	} else {
+		call = recoverMethod(method.route.Value, cr, newFuzzRequest(cr, result.HTTPMethod, result.URL))
+	}
+	result.Duration = time.Since(start)
+	result.Panic = call.panicValue
+	result.ReturnData = getReturnValues(call.retVal)
+	checkReturnedJSON(&result, getResponseTypes(controller)[method.methodName])
+	return result
+}
+
+// checkReturnedJSON inspects the (string, error) a method returned.  expected is nil when no response type is declared
+func checkReturnedJSON(result *MethodTestResult, expected interface{}) {
+	if len(result.ReturnData) == 0 {
+		return
+	}
+	body, ok := result.ReturnData[0].(string)
+	if !ok {
+		return
+	}
+	var err error
+	if len(result.ReturnData) > 1 {
+		err, _ = result.ReturnData[len(result.ReturnData)-1].(error)
+	}
+	if err != nil {
+		result.ErrorWithBody = body != ""
+		return
+	}
+	result.ValidJSON = json.Valid([]byte(body))
+	if expected != nil {
+		result.SchemaError = validateResponseType(body, expected)
+	}
+}
+
+type methodCall struct {
+	retVal     []reflect.Value
+	panicValue interface{}
 }
 
-func callMethod(method reflect.Value) []reflect.Value {
+// recoverMethod reports a panic in the method instead of letting it end the test run
+func recoverMethod(method reflect.Value, cr *ControllerRequest, r *http.Request) (call methodCall) {
+	defer func() {
+		call.panicValue = recover()
+	}()
+	call.retVal = callMethod(method, cr, r)
+	return call
+}
+
+// newFuzzRequest builds the request raw methods are called with.  path isn't parsed so that any ItemID can be fuzzed
+func newFuzzRequest(cr *ControllerRequest, httpMethod, path string) *http.Request {
+	r := &http.Request{Method: httpMethod, URL: &url.URL{Path: path}, RequestURI: path, Proto: "HTTP/1.1", ProtoMajor: 1, ProtoMinor: 1,
+		Header: make(http.Header), Body: http.NoBody, Host: "example.com"}
+	for name, value := range cr.Headers {
+		r.Header.Set(name, value)
+	}
+	return r.WithContext(cr.Context())
+}
+
+// callMethod passes r to raw methods, which read the request themselves
+func callMethod(method reflect.Value, cr *ControllerRequest, r *http.Request) []reflect.Value {
 	if isRawMethod(method.Type()) {
 		writer := httptest.NewRecorder()
-		args := []reflect.Value{reflect.ValueOf(&ControllerRequest{User: &User{UserID: 1}, Headers: make(map[string]string)}), reflect.ValueOf(writer), reflect.ValueOf(&http.Request{})}
+		args := []reflect.Value{reflect.ValueOf(cr), reflect.ValueOf(writer), reflect.ValueOf(r)}
 		method.Call(args)
 		return []reflect.Value{reflect.ValueOf(writer.Body.String())}
 	}
-	args := getArgs(method)
+	if isStreamMethod(method.Type()) {
+		writer := httptest.NewRecorder()
+		stream := newResponseStream(cr.Context(), writer, false)
+		err := method.Call([]reflect.Value{reflect.ValueOf(cr), reflect.ValueOf(stream)})[0]
+		if err.IsNil() {
+			stream.close()
+		}
+		return []reflect.Value{reflect.ValueOf(writer.Body.String()), err}
+	}
+	if isEventMethod(method.Type()) {
+		writer := httptest.NewRecorder()
+		ctx, cancel := context.WithTimeout(cr.Context(), fuzzEventTimeout)
+		defer cancel()
+		cr.ctx = ctx
+		rt := &route{events: method.Interface().(func(*ControllerRequest, chan<- Event) error)}
+		err := (&ControllerRoutingHandler{}).callEventMethod(cr, rt, writer)
+		return []reflect.Value{reflect.ValueOf(writer.Body.String()), reflect.ValueOf(&err).Elem()}
+	}
+	if isWebSocketMethod(method.Type()) {
+		server, client := net.Pipe()
+		client.Close() // the client hangs up straight away so the method sees io.EOF
+		ws := newWebSocket(server, bufio.NewReader(server))
+		defer ws.Close()
+		return method.Call([]reflect.Value{reflect.ValueOf(cr), reflect.ValueOf(ws)})
+	}
+	args := getArgs(method, cr)
 	return method.Call(args)
 }
 
-func getArgs(method reflect.Value) []reflect.Value {
+func getArgs(method reflect.Value, cr *ControllerRequest) []reflect.Value {
 	methodType := method.Type()
 	numArgs := methodType.NumIn()
 	args := make([]reflect.Value, numArgs, numArgs)
@@ -74,7 +253,7 @@ func getArgs(method reflect.Value) []reflect.Value {
 		switch methodType.In(i).Kind() {
 		case reflect.Ptr:
 			if i == 0 {
-				args[i] = reflect.ValueOf(&ControllerRequest{User: &User{UserID: 1}, Headers: make(map[string]string)})
+				args[i] = reflect.ValueOf(cr)
 				continue
 			}
 			myType := methodType.In(i)
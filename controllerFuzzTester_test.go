@@ -3,6 +3,8 @@ package oneweb
 import (
 	"fmt"
 	"testing"
+
+	"github.com/pkg/errors"
 )
 
 type MockTestRunner struct {
@@ -110,3 +112,54 @@ func TestFuzzTestControllerMethodPost(t *testing.T) {
 		t.Error("Problems with Post", result)
 	}
 }
+
+type TypedController struct {
+}
+
+func (c *TypedController) Index(cr *ControllerRequest) (string, error) {
+	return `[{"Hello":"world"}]`, nil
+}
+
+func (c *TypedController) Get(cr *ControllerRequest) (string, error) {
+	return `{"id":"1","tags":[]}`, nil
+}
+
+func (c *TypedController) GetBroken(cr *ControllerRequest) (string, error) {
+	return "not json", errors.New("failed")
+}
+
+func (c *TypedController) ResponseTypes() map[string]interface{} {
+	return map[string]interface{}{"Index": []SimpleData{}, "Get": projectSchema}
+}
+
+func TestFuzzTestControllerResponseTypes(t *testing.T) {
+	results := FuzzTestController(&TypedController{})
+	if len(results) != 3 {
+		t.Fatal("expected ResponseTypes to be skipped", results)
+	}
+	get, broken, index := results[0], results[1], results[2]
+	if !index.ValidJSON || index.SchemaError != nil || index.ErrorWithBody {
+		t.Error("expected Index to match its Go type", index)
+	}
+	if !get.ValidJSON || get.SchemaError == nil || get.SchemaError.Error() != "$.id: expected integer, got string" {
+		t.Error("expected Get to violate its schema", get)
+	}
+	if broken.ValidJSON || !broken.ErrorWithBody {
+		t.Error("expected error returned with a body", broken)
+	}
+}
+
+func TestAutoFuzzTestControllerSchemaErrors(t *testing.T) {
+	tester := &MockTestRunner{}
+	AutoFuzzTestController(tester, &TypedController{})
+	if len(tester.Errors) != 1 || len(tester.Messages) != 3 {
+		t.Error("expected schema error for Get", tester.Errors)
+	}
+}
+
+func TestRegisterControllerResponseTypeErrors(t *testing.T) {
+	err := NewControllerRoutingHandler().RegisterController("typed", &TypedController{})
+	if err.Error() != "" {
+		t.Error("expected ResponseTypes to be skipped by the router", err)
+	}
+}
@@ -3,28 +3,120 @@ package oneweb
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
 	"net/http"
 	"reflect"
-	"strings"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
 type ControllerRoutingHandler struct {
-	Controllers       map[string]interface{}
-	controllerMethods map[string]*reflect.Value
+	// Controllers is a snapshot of the registered controllers keyed by the name they were registered with.  It is
+	// replaced on every registration change, so it must not be read while controllers are being registered.
+	// Changing it has no effect on routing
+	//
+	// Deprecated: Use RegisteredControllers, which is safe to call while controllers are being registered
+	Controllers          map[string]interface{}
+	Naming               NamingStrategy // must be set before controllers are registered
+	ReuseRequests        bool           // pool ControllerRequests between requests.  Controllers must not keep cr after returning
+	Logger               Logger         // defaults to slog.Default()
+	LogLevels            LogLevels
+	LogHeaders           bool     // include ControllerRequest.Headers in the access log
+	RedactHeaders        []string // header values replaced with [REDACTED] when LogHeaders is set
+	Metrics              Metrics
+	Tracer               Tracer
+	ResponseCache        ResponseCacheStore // used by methods with a CachePolicy.  Nil disables response caching
+	Compression          *Compression       // nil disables response compression
+	SSEHeartbeat         time.Duration      // interval between Server-Sent Events heartbeat comments, 15s if not set
+	WebSocketOrigins     []string           // browser origins besides the server's own, e.g. https://app.example.com, which may open WebSockets.  "*" allows any
+	WebSocketIdleTimeout time.Duration      // WebSockets which receive nothing for this long are closed, 1 minute if not set
+	RateLimits           RateLimitStore     // used by methods with a RateLimit.  Nil disables rate limiting
+	Idempotency          IdempotencyStore   // remembers POST responses by Idempotency-Key.  Nil ignores the header
+	IdempotencyTTL       time.Duration      // how long Idempotency-Keys are remembered, 24 hours if not set
+	Batch                bool               // serve JSON arrays of sub-requests POSTed to /_batch
+	BatchParallel        int                // how many sub-requests of a batch run at once, 1 if not set
+	Recorder             *TrafficRecorder   // writes sanitized requests and responses as JSONL.  Nil disables recording
+	registerLock         sync.Mutex
+	table                atomic.Value // *routingTable, replaced as a whole on every registration change
 }
 
 func NewControllerRoutingHandler() *ControllerRoutingHandler {
-	return &ControllerRoutingHandler{Controllers: make(map[string]interface{}), controllerMethods: make(map[string]*reflect.Value)}
+	return &ControllerRoutingHandler{Controllers: make(map[string]interface{}), Naming: LegacyNaming, LogLevels: DefaultLogLevels, RedactHeaders: DefaultRedactHeaders}
 }
 
+// RegisterController adds the controller's valid methods to the routing table, replacing any controller
+// already registered under the same name.  It is safe to call while the handler is serving requests
 func (c *ControllerRoutingHandler) RegisterController(name string, controller interface{}) error {
-	c.Controllers[name] = controller
-	return c.addValidControllerMethods(controller, name)
+	c.registerLock.Lock()
+	defer c.registerLock.Unlock()
+	naming := c.naming()
+	table := c.loadTable().without(getControllerKey(name, naming))
+	err := table.addValidControllerMethods(controller, name, naming)
+	c.storeTable(table)
+	return err
+}
+
+// UnregisterController removes the named controller and its routes.  It returns false if no such controller was registered
+func (c *ControllerRoutingHandler) UnregisterController(name string) bool {
+	c.registerLock.Lock()
+	defer c.registerLock.Unlock()
+	controllerKey := getControllerKey(name, c.naming())
+	current := c.loadTable()
+	if !current.hasController(controllerKey) {
+		return false
+	}
+	c.storeTable(current.without(controllerKey))
+	return true
+}
+
+// RegisteredControllers returns the registered controllers keyed by the name they were registered with.  It reads
+// the current routing table without locking, so it is safe to call at any time.  Changing the map has no effect on routing
+func (c *ControllerRoutingHandler) RegisteredControllers() map[string]interface{} {
+	table := c.loadTable()
+	controllers := make(map[string]interface{}, len(table.controllers))
+	for name, controller := range table.controllers {
+		controllers[name] = controller
+	}
+	return controllers
+}
+
+// ReplaceControllers builds a new routing table from controllers and swaps it in atomically, so in-flight
+// requests finish against the old controllers and new requests only see the new set.  Of names which map to the
+// same URL, such as Projects and projects, only the first in sorted order is registered and the rest are errors
+func (c *ControllerRoutingHandler) ReplaceControllers(controllers map[string]interface{}) error {
+	c.registerLock.Lock()
+	defer c.registerLock.Unlock()
+	naming := c.naming()
+	names := make([]string, 0, len(controllers))
+	for name := range controllers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	table := newRoutingTable()
+	registered := make(map[string]string)
+	var errMsg string
+	for _, name := range names {
+		controllerKey := getControllerKey(name, naming)
+		if other, ok := registered[controllerKey]; ok {
+			errMsg += fmt.Sprintf("Controller \"%s\" error: Same URL as controller \"%s\"\n", name, other)
+			continue
+		}
+		registered[controllerKey] = name
+		errMsg += table.addValidControllerMethods(controllers[name], name, naming).Error()
+	}
+	c.storeTable(table)
+	return errors.New(errMsg)
+}
+
+// storeTable publishes the table and a new snapshot of its controllers.  Callers hold registerLock
+func (c *ControllerRoutingHandler) storeTable(table *routingTable) {
+	c.table.Store(table)
+	c.Controllers = c.RegisteredControllers()
 }
 
 func (c *ControllerRoutingHandler) Handler() http.Handler {
@@ -32,71 +124,196 @@ func (c *ControllerRoutingHandler) Handler() http.Handler {
 }
 
 func (c *ControllerRoutingHandler) controllerRoutingHandler(rw http.ResponseWriter, r *http.Request) {
-	startTime := time.Now()
-	cr := newControllerRequest(r)
-	methodName := getMethodName(r.Method, cr)
-	err := checkUrl(r.Method, methodName, cr)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusInternalServerError)
-		log.Println("Error:  ", r.Method, r.URL.Path, time.Since(startTime), err)
+	if c.Batch && r.URL.Path == BatchPath {
+		c.serveBatch(rw, r)
 		return
 	}
+	startTime := time.Now()
+	var cr *ControllerRequest
+	if c.ReuseRequests {
+		cr = getPooledControllerRequest(r, c.naming())
+		defer releaseControllerRequest(cr)
+	} else {
+		cr = newControllerRequest(r, c.naming())
+	}
+	sw := &statusWriter{ResponseWriter: rw}
+	sw.Header().Set(RequestIDHeader, cr.RequestID)
+	key := getRouteKey(r.Method, cr)
+	method := c.getRoute(key)
+	controllerLabel, methodLabel := getMetricLabels(key, method)
+	c.metrics().RequestStarted(controllerLabel, methodLabel)
+	span := c.startRequestSpan(r, cr, cr.ControllerName+"."+getLoggedMethodName(key, method))
+	var err error
+	defer func() { // runs on panic too, so the in-flight gauge, span and access log are always completed
+		p := recover()
+		if p != nil {
+			err = fmt.Errorf("Panic calling controller method: %v", p)
+			if sw.status == 0 { // nothing was sent, and net/http aborts the response, so report it as failed
+				sw.status = http.StatusInternalServerError
+			}
+		}
+		span.SetAttribute("http.status_code", sw.Status())
+		endSpan(span, err)
+		duration := time.Since(startTime)
+		c.metrics().RequestFinished(controllerLabel, methodLabel, sw.Status(), duration)
+		c.logRequest(r, cr, getLoggedMethodName(key, method), sw, duration, err)
+		if p != nil {
+			panic(p) // let net/http handle the panic as it would without the router
+		}
+	}()
+	tw := c.Recorder.start(sw, r)
+	cw := c.compressWriter(tw, r)
+	err = c.callRoute(cw, r, cr, key, method)
+	closeCompressWriter(cw)
+	if err != nil && sw.status == 0 { // a response which has already started can't be replaced with an error
+		writeError(tw, getErrorStatus(err), err, cr.RequestID)
+	}
+	c.Recorder.finish(tw, cr, sw.Status())
+}
 
-	method := c.getMethod(cr.ControllerName, methodName)
+func (c *ControllerRoutingHandler) callRoute(rw http.ResponseWriter, r *http.Request, cr *ControllerRequest, key routeKey, method *route) error {
+	if err := checkUrl(r.Method, key.verb, cr); err != nil {
+		return err
+	}
 	if method == nil {
-		http.Error(rw, "Method \""+methodName+"\" not found", http.StatusInternalServerError)
-		log.Println("Error:  ", r.Method, r.URL.Path, time.Since(startTime), "Method \""+methodName+"\" not found")
-		return
+		return fmt.Errorf("Method \"%s\" not found", key.verb+key.action)
+	}
+	if err := c.checkRateLimit(rw, r, cr, key, method); err != nil {
+		return err
 	}
+	if c.Idempotency != nil && key.verb == "Post" && r.Header.Get(IdempotencyKeyHeader) != "" {
+		return c.callIdempotent(rw, r, cr, key, method)
+	}
+	return c.callMethod(rw, r, cr, key, method)
+}
 
-	if isRawMethod(method.Type()) {
+func (c *ControllerRoutingHandler) callMethod(rw http.ResponseWriter, r *http.Request, cr *ControllerRequest, key routeKey, method *route) error {
+	if method.raw != nil {
+		span := c.startSpan(cr, "invoke")
 		callRawMethod(cr, method, rw, r)
-		return
+		span.End()
+		c.invalidateResponseCache(key)
+		return nil
 	}
 
+	if method.isStreaming() {
+		span := c.startSpan(cr, "invoke")
+		err := c.callStreamingMethod(cr, method, rw, r)
+		endSpan(span, err)
+		return err
+	}
+
+	if err := c.checkPreconditions(r, cr, key); err != nil {
+		return err
+	}
+
+	span := c.startSpan(cr, "decode")
 	json, err := getJSONBody(r, method)
+	endSpan(span, err)
 	if err != nil {
-		http.Error(rw, "Failed to read JSON data: "+err.Error(), http.StatusInternalServerError)
-		log.Println("Error:  ", r.Method, r.URL.Path, time.Since(startTime), err)
-		return
+		return errors.Wrap(err, "Failed to read JSON data")
 	}
 
-	arguments := getRequestArguments(r.Method, cr, json)
-	retVal, err := callControllerMethod(method, arguments)
+	span = c.startSpan(cr, "invoke")
+	retVal, err := c.callWithResponseCache(rw, r, cr, key, method, json)
+	endSpan(span, err)
 	if err != nil {
-		http.Error(rw, "Internal error calling controller method: "+err.Error(), http.StatusInternalServerError)
-		log.Println("Error:  ", r.Method, r.URL.Path, time.Since(startTime), err)
-		return
+		return errors.Wrap(err, "Internal error calling controller method")
+	}
+
+	span = c.startSpan(cr, "encode")
+	if key.verb == "Index" || key.verb == "Get" {
+		writeCacheableResponse(rw, r, cr, retVal)
+	} else {
+		writeResponse(rw, retVal)
 	}
+	span.End()
+	return nil
+}
 
-	writeResponse(rw, retVal)
+func (c *ControllerRoutingHandler) callStreamingMethod(cr *ControllerRequest, method *route, rw http.ResponseWriter, r *http.Request) error {
+	switch {
+	case method.events != nil:
+		return c.callEventMethod(cr, method, rw)
+	case method.socket != nil:
+		return c.callWebSocketMethod(cr, method, rw, r)
+	}
+	return callStreamMethod(cr, method, rw, r)
+}
+
+// startRequestSpan starts the span covering the whole request, parented on the incoming W3C traceparent.
+// The span's context becomes cr.Context() so that controllers can create their own child spans
+func (c *ControllerRoutingHandler) startRequestSpan(r *http.Request, cr *ControllerRequest, name string) Span {
+	ctx := cr.Context()
+	if sc, ok := parseTraceParent(r.Header.Get("Traceparent"), r.Header.Get("Tracestate")); ok {
+		ctx = ContextWithSpanContext(ctx, sc)
+	}
+	ctx, span := c.tracer().Start(ctx, name)
+	cr.ctx = ctx
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("request.id", cr.RequestID)
+	span.SetAttribute("http.target", r.URL.Path)
+	return span
 }
 
-func (c *ControllerRoutingHandler) addValidControllerMethods(controller interface{}, controllerName string) error {
-	controllerValue := reflect.ValueOf(controller)
-	controllerType := controllerValue.Type()
-	numMethod := controllerValue.NumMethod()
+func (t *routingTable) addValidControllerMethods(controller interface{}, controllerName string, naming NamingStrategy) error {
+	t.controllers[controllerName] = controller
+	t.controllerKeys[controllerName] = getControllerKey(controllerName, naming)
+	methods, optionErrMsg := discoverRoutes(controller, controllerName, naming)
 	var errMsg string
-	for i := 0; i < numMethod; i++ {
-		methodName := controllerType.Method(i).Name
-		if strings.ToLower(methodName[:1]) == methodName[:1] { // private method (lowercase first letter), so skip
-			continue
-		}
-		method := controllerValue.Method(i)
-		httpVerb, action, err := validateMethod(method, methodName)
-		if err != nil {
-			errMsg += err.Error() + "\n"
-		} else {
-			c.controllerMethods[strings.Title(strings.ToLower(controllerName))+strings.Title(strings.ToLower(httpVerb))+strings.Title(strings.ToLower(action))] = &method
+	for _, method := range methods {
+		errMsg += method.errMsg
+		if method.route != nil {
+			t.routes[method.key] = method.route
 		}
 	}
-	return errors.New(errMsg)
+	return errors.New(errMsg + optionErrMsg)
+}
+
+func (c *ControllerRoutingHandler) naming() NamingStrategy {
+	if c.Naming == nil {
+		return LegacyNaming
+	}
+	return c.Naming
 }
 
 func writeResponse(rw http.ResponseWriter, json string) {
 	rw.Header().Add("Access-Control-Allow-Origin", "*")
 	rw.Header().Add("Content-Type", "application/json")
-	fmt.Fprintf(rw, json)
+	io.WriteString(rw, json)
+}
+
+// statusError carries the HTTP status an error should be reported with.  Other errors are reported as 500
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func withStatus(status int, err error) error {
+	return &statusError{status, err}
+}
+
+func getErrorStatus(err error) int {
+	if statusErr, ok := err.(*statusError); ok {
+		return statusErr.status
+	}
+	return http.StatusInternalServerError
+}
+
+type errorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"requestId,omitempty"`
+}
+
+func writeError(rw http.ResponseWriter, status int, err error, requestID string) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.WriteHeader(status)
+	json.NewEncoder(rw).Encode(errorResponse{err.Error(), requestID})
 }
 
 func checkUrl(httpVerb, methodName string, cr *ControllerRequest) error {
@@ -121,41 +338,14 @@ func checkUrl(httpVerb, methodName string, cr *ControllerRequest) error {
 }
 
 func getMethodName(httpVerb string, cr *ControllerRequest) string {
-	methodName := strings.Title(strings.ToLower(httpVerb))
-	if methodName == "Get" && cr.ItemID == "" && cr.Action == "" && cr.ActionFilter == "" {
-		methodName = "Index"
-	}
-
-	if methodName != "Index" && cr.Action != "" {
-		methodName = methodName + cr.Action
-	}
-	return methodName
+	key := getRouteKey(httpVerb, cr)
+	return key.verb + key.action
 }
 
-func (c *ControllerRoutingHandler) getMethod(controllerName string, methodName string) *reflect.Value {
-	return c.controllerMethods[controllerName+methodName]
-}
-
-func getJSONBody(r *http.Request, method *reflect.Value) (interface{}, error) {
-	if r.Method == "POST" || r.Method == "PUT" {
-		outType := method.Type().In(method.Type().NumIn() - 1)
-		pointer := isPointer(outType)
-		var data reflect.Value
-		if pointer {
-			data = reflect.New(outType.Elem()) // pointer of pointer doesn't work
-		} else {
-			data = reflect.New(outType)
-		}
+func getJSONBody(r *http.Request, method *route) (interface{}, error) {
+	if (r.Method == "POST" || r.Method == "PUT") && method.decodeBody != nil {
 		defer r.Body.Close()
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			return data.Interface(), err
-		}
-		err = json.Unmarshal(body, data.Interface())
-		if pointer {
-			return data.Interface(), err
-		}
-		return data.Elem().Interface(), err
+		return method.decodeBody(r.Body)
 	}
 	return nil, nil
 }
@@ -168,8 +358,8 @@ func getRequestArguments(httpVerb string, cr *ControllerRequest, json interface{
 	return args
 }
 
-func callRawMethod(cr *ControllerRequest, method *reflect.Value, rw http.ResponseWriter, r *http.Request) {
-	method.Call([]reflect.Value{reflect.ValueOf(cr), reflect.ValueOf(rw), reflect.ValueOf(r)})
+func callRawMethod(cr *ControllerRequest, method *route, rw http.ResponseWriter, r *http.Request) {
+	method.raw(cr, rw, r)
 }
 
 func callControllerMethod(method *reflect.Value, arguments []reflect.Value) (string, error) {
@@ -2,6 +2,7 @@ package oneweb
 
 import (
 	"bytes"
+	"encoding/json"
 	"github.com/pkg/errors"
 	"io"
 	"io/ioutil"
@@ -35,7 +36,7 @@ Method "GetTooFewReturns" error: Unsupported return type.  Expected (string, err
 Method "GetWrongReturnType" error: Unsupported return type.  Expected (string, error)
 Method "PutBogus" error: Requires 2 input args (cr *ControllerRequest, json *YourStruct or []YourStruct)
 `
-	if len(router.controllerMethods) != 8 || expectedErr != err.Error() {
+	if len(router.loadTable().routes) != 8 || expectedErr != err.Error() {
 		t.Fatal("expected 8 valid controller methods with errors for other 5: ", err)
 	}
 }
@@ -72,7 +73,7 @@ func TestGetMethodNameUpdate(t *testing.T) {
 func TestGetMethod(t *testing.T) {
 	router := NewControllerRoutingHandler()
 	router.RegisterController("Test", &MockController{})
-	method := router.getMethod("Test", "Index")
+	method := router.getRoute(routeKey{"Test", "Index", ""})
 	retVal := method.Call([]reflect.Value{reflect.ValueOf(&ControllerRequest{})})
 	if retVal[0].String() != "called Index" {
 		t.Fatal("expected to be able to call Index method")
@@ -82,7 +83,7 @@ func TestGetMethod(t *testing.T) {
 func TestGetMethodFailed(t *testing.T) {
 	router := NewControllerRoutingHandler()
 	router.RegisterController("Test", &MockController{})
-	method := router.getMethod("Test", "GetStuff")
+	method := router.getRoute(routeKey{"Test", "Get", "Stuff"})
 	if method != nil {
 		t.Fatal("expected to receive empty method")
 	}
@@ -91,7 +92,7 @@ func TestGetMethodFailed(t *testing.T) {
 func TestIsRawMethod(t *testing.T) {
 	router := NewControllerRoutingHandler()
 	router.RegisterController("Test", &MockController{})
-	method := router.getMethod("Test", "GetRawmethod")
+	method := router.getRoute(routeKey{"Test", "Get", "Rawmethod"})
 	isRaw := isRawMethod(method.Type())
 	if !isRaw {
 		t.Fatal("expected to be raw method")
@@ -101,7 +102,7 @@ func TestIsRawMethod(t *testing.T) {
 func TestIsRawPostMethod(t *testing.T) {
 	router := NewControllerRoutingHandler()
 	router.RegisterController("Test", &MockController{})
-	method := router.getMethod("Test", "Post")
+	method := router.getRoute(routeKey{"Test", "Post", ""})
 	isRaw := isRawMethod(method.Type())
 	if !isRaw {
 		t.Fatal("expected to be raw method")
@@ -113,7 +114,7 @@ func TestCallRawGetMethod(t *testing.T) {
 	router.RegisterController("Test", &MockController{})
 	writer := httptest.NewRecorder()
 	req := &ControllerRequest{ItemID: "1234", Action: "Rawmethod"}
-	method := router.getMethod("Test", "GetRawmethod")
+	method := router.getRoute(routeKey{"Test", "Get", "Rawmethod"})
 	callRawMethod(req, method, writer, &http.Request{})
 	if writer.Body.String() != "called raw GET method" {
 		t.Fatal("expected to call raw method")
@@ -125,7 +126,7 @@ func TestCallRawPostMethod(t *testing.T) {
 	req := ControllerRequest{}
 	router := NewControllerRoutingHandler()
 	router.RegisterController("Test", &MockController{})
-	method := router.getMethod("Test", "Post")
+	method := router.getRoute(routeKey{"Test", "Post", ""})
 	callRawMethod(&req, method, writer, &http.Request{})
 	if writer.Body.String() != "called raw POST method" {
 		t.Fatal("expected to call raw method")
@@ -138,7 +139,7 @@ type SimpleData struct {
 
 func TestGetJsonBody(t *testing.T) {
 	router := getMockRouter()
-	method := router.getMethod("Projects", "Put")
+	method := router.getRoute(routeKey{"Projects", "Put", ""})
 	data, err := getJSONBody(&http.Request{Method: "PUT", Body: ioutil.NopCloser(bytes.NewBufferString(`{ "hello": "there" }`))}, method)
 	if err != nil || data.(*SimpleData).Hello != "there" {
 		t.Fatal("expected json object with property hello and value there")
@@ -154,13 +155,22 @@ func TestGetJsonBodyNotPostOrPut(t *testing.T) {
 
 func TestGetJsonErrors(t *testing.T) {
 	router := getMockRouter()
-	method := router.getMethod("Projects", "Post")
+	method := router.getRoute(routeKey{"Projects", "Put", ""})
 	_, err := getJSONBody(&http.Request{Method: "POST", Body: &MockErroringReadCloser{}}, method)
 	if err == nil {
 		t.Fatal("expected error")
 	}
 }
 
+func TestGetJsonBodySkipsRawMethods(t *testing.T) {
+	router := getMockRouter()
+	method := router.getRoute(routeKey{"Projects", "Post", ""})
+	data, err := getJSONBody(&http.Request{Method: "POST", Body: &MockErroringReadCloser{}}, method)
+	if data != nil || err != nil {
+		t.Fatal("expected raw method's body to be left for the method to read", data, err)
+	}
+}
+
 func TestGetArgumentsForIndexPage(t *testing.T) {
 	args := getRequestArguments("GET", &ControllerRequest{}, "1234")
 	if len(args) != 1 {
@@ -264,7 +274,7 @@ func TestCallControllerMethodWithError(t *testing.T) {
 func TestHttpHandlerSuccess(t *testing.T) {
 	rw := httptest.NewRecorder()
 	getMockRouter().controllerRoutingHandler(rw, newHttpRequest("GET", "/projects", nil))
-	if len(rw.HeaderMap) != 2 {
+	if len(rw.HeaderMap) != 4 || rw.Header().Get(RequestIDHeader) == "" || rw.Header().Get("ETag") == "" {
 		t.Fatal("expected to succeed")
 	}
 }
@@ -272,7 +282,7 @@ func TestHttpHandlerSuccess(t *testing.T) {
 func TestHttpHandlerMethodNotFound(t *testing.T) {
 	rw := httptest.NewRecorder()
 	getMockRouter().controllerRoutingHandler(rw, newHttpRequest("GET", "/projects/123/bogus", nil))
-	if rw.Body.String() != "Method \"GetBogus\" not found\n" {
+	if getErrorMessage(rw) != "Method \"GetBogus\" not found" {
 		t.Fatal("expected to be unable to find method", rw.Body.String())
 	}
 }
@@ -296,7 +306,7 @@ func TestHttpHandlerCallRaw(t *testing.T) {
 func TestHttpHandlerCantGetJson(t *testing.T) {
 	rw := httptest.NewRecorder()
 	getMockRouter().controllerRoutingHandler(rw, newHttpRequest("PUT", "/projects/123/valid", &MockErroringReadCloser{}))
-	if !strings.Contains(rw.Body.String(), "Failed to read JSON data:") {
+	if !strings.Contains(getErrorMessage(rw), "Failed to read JSON data:") {
 		t.Fatal("expected failure getting JSON", rw.Body.String())
 	}
 }
@@ -304,7 +314,7 @@ func TestHttpHandlerCantGetJson(t *testing.T) {
 func TestHttpHandlerInvalidArguments(t *testing.T) {
 	rw := httptest.NewRecorder()
 	getMockRouter().controllerRoutingHandler(rw, newHttpRequest("PUT", "/projects", ioutil.NopCloser(bytes.NewBufferString(`{ "hello": "there" }`))))
-	if body := rw.Body.String(); body != "Malformed URL. Expected: /Projects/{id}\n" {
+	if body := getErrorMessage(rw); body != "Malformed URL. Expected: /Projects/{id}" {
 		t.Fatal("should've gotten bogus arguments: ", body)
 	}
 }
@@ -312,7 +322,7 @@ func TestHttpHandlerInvalidArguments(t *testing.T) {
 func TestHttpHandlerErroringMethod(t *testing.T) {
 	rw := httptest.NewRecorder()
 	getMockRouter().controllerRoutingHandler(rw, newHttpRequest("GET", "/projects/1/error", nil))
-	if rw.Body.String() != "Internal error calling controller method: failed\n" {
+	if getErrorMessage(rw) != "Internal error calling controller method: failed" {
 		t.Fatal("should've had an error: ", rw.Body.String())
 	}
 }
@@ -324,6 +334,14 @@ func TestHandler(t *testing.T) {
 	}
 }
 
+func getErrorMessage(rw *httptest.ResponseRecorder) string {
+	var errResponse errorResponse
+	if err := json.Unmarshal(rw.Body.Bytes(), &errResponse); err != nil || errResponse.RequestID != rw.Header().Get(RequestIDHeader) {
+		return "invalid error response: " + rw.Body.String()
+	}
+	return errResponse.Error
+}
+
 type MockErroringReadCloser struct {
 	io.ReadCloser
 }
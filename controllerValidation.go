@@ -16,6 +16,26 @@ func isRawMethod(methodType reflect.Type) bool {
 		methodType.In(2) == reflect.TypeOf((*http.Request)(nil))
 }
 
+func isStreamMethod(methodType reflect.Type) bool {
+	return isStreamingSignature(methodType, reflect.TypeOf((*ResponseStream)(nil)))
+}
+
+func isEventMethod(methodType reflect.Type) bool {
+	return isStreamingSignature(methodType, reflect.TypeOf((chan<- Event)(nil)))
+}
+
+func isWebSocketMethod(methodType reflect.Type) bool {
+	return isStreamingSignature(methodType, reflect.TypeOf((*WebSocket)(nil)))
+}
+
+// isStreamingSignature matches (cr *ControllerRequest, stream streamType) error
+func isStreamingSignature(methodType reflect.Type, streamType reflect.Type) bool {
+	return methodType.NumIn() == 2 && methodType.NumOut() == 1 &&
+		isControllerRequestArg(methodType.In(0)) &&
+		methodType.In(1) == streamType &&
+		isErrorArg(methodType.Out(0))
+}
+
 func validateMethod(method reflect.Value, methodName string) (httpVerb string, action string, err error) {
 	httpVerb, action = parseMethod(methodName)
 	if httpVerb == "" {
@@ -31,6 +51,13 @@ func validateMethod(method reflect.Value, methodName string) (httpVerb string, a
 		return httpVerb, action, nil
 	}
 
+	if isStreamMethod(methodType) || isEventMethod(methodType) || isWebSocketMethod(methodType) {
+		if httpVerb != "Index" && httpVerb != "Get" {
+			return httpVerb, action, fmt.Errorf("Method \"%s\" error: Only Get and Index methods can stream responses", methodName)
+		}
+		return httpVerb, action, nil
+	}
+
 	if !isJSONReturnArgs(methodType) {
 		return httpVerb, action, fmt.Errorf("Method \"%s\" error: Unsupported return type.  Expected (string, error)", methodName)
 	}
@@ -51,10 +78,10 @@ func validateMethod(method reflect.Value, methodName string) (httpVerb string, a
 }
 
 func parseMethod(methodName string) (string, string) {
-	methodName = strings.Title(strings.ToLower(methodName))
+	titleName := strings.Title(strings.ToLower(methodName))
 	for _, prefix := range []string{"Index", "Get", "Put", "Post", "Delete"} {
-		if strings.Index(methodName, prefix) == 0 {
-			return prefix, strings.Title(strings.ToLower(methodName[len(prefix):len(methodName)]))
+		if strings.Index(titleName, prefix) == 0 {
+			return prefix, methodName[len(prefix):len(methodName)] // keep the action's case so naming strategies can split it into words
 		}
 	}
 	return "", ""
@@ -0,0 +1,181 @@
+package oneweb
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FuzzOptions sets the requests controller methods are fuzzed with.  Every method is called once for each
+// combination of Users, Headers, ItemIDs and ActionFilters.  An empty field contributes a single default:
+// a user with UserID 1, no headers, and an empty ItemID and ActionFilter
+type FuzzOptions struct {
+	Users         []*User // &User{} is an anonymous user
+	Headers       []map[string]string
+	ItemIDs       []string
+	ActionFilters []string
+	Parallel      int           // method calls run at once, 1 if not set
+	Timeout       time.Duration // calls still running after this are reported as TimedOut.  No limit if not set
+	// ControllerName and Naming build the URLs in results.  Set them to the name passed to RegisterController
+	// and the router's Naming, or the URLs won't match the router's.  ControllerName defaults to a guess from
+	// the type name without its Controller suffix, e.g. /mock for MockController, and Naming to LegacyNaming
+	ControllerName string
+	Naming         NamingStrategy
+}
+
+// FuzzInput is the combination of options one method call was made with
+type FuzzInput struct {
+	User         *User
+	Headers      map[string]string
+	ItemID       string
+	ActionFilter string
+}
+
+func (in FuzzInput) String() string {
+	userID := 0
+	if in.User != nil {
+		userID = in.User.UserID
+	}
+	return fmt.Sprintf("UserID %d, Headers %v, ItemID \"%s\", ActionFilter \"%s\"", userID, in.Headers, in.ItemID, in.ActionFilter)
+}
+
+// UserFromJSON builds the User the router would derive from an X-User header, e.g. for admin payloads
+func UserFromJSON(userJSON string) *User {
+	user := &User{}
+	json.Unmarshal([]byte(userJSON), user)
+	user.JSON = userJSON
+	return user
+}
+
+func (o FuzzOptions) controllerName(controller interface{}) string {
+	if o.ControllerName != "" {
+		return o.ControllerName
+	}
+	name := strings.TrimSuffix(reflect.Indirect(reflect.ValueOf(controller)).Type().Name(), "Controller")
+	if name == "" {
+		return httpFuzzController
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func (o FuzzOptions) naming() NamingStrategy {
+	if o.Naming == nil {
+		return LegacyNaming
+	}
+	return o.Naming
+}
+
+func (o FuzzOptions) parallel() int {
+	if o.Parallel <= 0 {
+		return 1
+	}
+	return o.Parallel
+}
+
+func (o FuzzOptions) combinations() []FuzzInput {
+	users := o.Users
+	if len(users) == 0 {
+		users = []*User{{UserID: 1}}
+	}
+	headers := o.Headers
+	if len(headers) == 0 {
+		headers = []map[string]string{nil}
+	}
+	itemIDs := o.ItemIDs
+	if len(itemIDs) == 0 {
+		itemIDs = []string{""}
+	}
+	actionFilters := o.ActionFilters
+	if len(actionFilters) == 0 {
+		actionFilters = []string{""}
+	}
+
+	inputs := make([]FuzzInput, 0, len(users)*len(headers)*len(itemIDs)*len(actionFilters))
+	for _, user := range users {
+		for _, header := range headers {
+			for _, itemID := range itemIDs {
+				for _, actionFilter := range actionFilters {
+					inputs = append(inputs, FuzzInput{user, header, itemID, actionFilter})
+				}
+			}
+		}
+	}
+	return inputs
+}
+
+// newControllerRequest copies the input so that methods which modify cr can't affect other combinations
+func (in FuzzInput) newControllerRequest() *ControllerRequest {
+	user := User{}
+	if in.User != nil {
+		user = *in.User
+	}
+	headers := make(map[string]string, len(in.Headers))
+	for name, value := range in.Headers {
+		headers[name] = value
+	}
+	return &ControllerRequest{User: &user, Headers: headers, ItemID: in.ItemID, ActionFilter: in.ActionFilter}
+}
+
+// FuzzCoverage summarizes which controller methods a fuzz run exercised
+type FuzzCoverage struct {
+	Exercised map[string][]string // HTTP verb prefix (Get, Put, ...) => methods which were called
+	Skipped   map[string]error    // methods which weren't called because the router rejects them
+	TimedOut  []string
+	Calls     int
+}
+
+// SummarizeFuzzResults reports the methods and verbs covered by results from FuzzTestControllerWithOptions
+func SummarizeFuzzResults(results []MethodTestResult) FuzzCoverage {
+	coverage := FuzzCoverage{Exercised: make(map[string][]string), Skipped: make(map[string]error)}
+	seen := make(map[string]bool)
+	for _, result := range results {
+		if result.HTTPMethod == "" {
+			coverage.Skipped[result.MethodName] = result.ValidationError
+			continue
+		}
+		coverage.Calls++
+		if result.TimedOut {
+			coverage.TimedOut = appendUnique(coverage.TimedOut, result.MethodName)
+		}
+		if !seen[result.MethodName] {
+			seen[result.MethodName] = true
+			verb, _ := parseMethod(result.MethodName)
+			coverage.Exercised[verb] = append(coverage.Exercised[verb], result.MethodName)
+		}
+	}
+	return coverage
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, item := range values {
+		if item == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+func (c FuzzCoverage) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%d calls\n", c.Calls)
+	for _, verb := range []string{"Index", "Get", "Put", "Post", "Delete"} {
+		if methods := c.Exercised[verb]; len(methods) > 0 {
+			fmt.Fprintf(b, "%s: %s\n", verb, strings.Join(methods, ", "))
+		}
+	}
+	if len(c.TimedOut) > 0 {
+		fmt.Fprintf(b, "Timed out: %s\n", strings.Join(c.TimedOut, ", "))
+	}
+	skipped := make([]string, 0, len(c.Skipped))
+	for methodName := range c.Skipped {
+		skipped = append(skipped, methodName)
+	}
+	sort.Strings(skipped)
+	for _, methodName := range skipped {
+		fmt.Fprintf(b, "Skipped %s: %v\n", methodName, c.Skipped[methodName])
+	}
+	return b.String()
+}
@@ -0,0 +1,172 @@
+package oneweb
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type AuthController struct {
+}
+
+func (c *AuthController) Get(cr *ControllerRequest) (string, error) {
+	if !strings.Contains(cr.User.JSON, `"Admin":true`) {
+		return "", errors.New("forbidden")
+	}
+	cr.User.UserID = 0 // methods can't affect other combinations
+	cr.Headers["Seen"] = "true"
+	return `{"item":"` + cr.ItemID + `","filter":"` + cr.ActionFilter + `","lang":"` + cr.Headers["Accept-Language"] + `"}`, nil
+}
+
+func TestFuzzOptionsCombinations(t *testing.T) {
+	admin := UserFromJSON(`{"UserID":2,"Admin":true}`)
+	options := FuzzOptions{
+		Users:   []*User{{}, admin},
+		Headers: []map[string]string{{"Accept-Language": "en"}, {"Accept-Language": "fr"}},
+		ItemIDs: []string{"1", "abc"},
+	}
+	results := FuzzTestControllerWithOptions(&AuthController{}, options)
+	if len(results) != 8 {
+		t.Fatal("expected a result per combination", len(results))
+	}
+	if results[0].Input.User.UserID != 0 || results[0].ReturnData[1].(error).Error() != "forbidden" {
+		t.Error("expected anonymous user to be forbidden", results[0])
+	}
+	last := results[7]
+	if last.Input.User != admin || last.Input.ItemID != "abc" || last.ReturnData[0] != `{"item":"abc","filter":"","lang":"fr"}` || !last.ValidJSON {
+		t.Error("expected admin combination to succeed", last)
+	}
+	if admin.UserID != 2 || options.Headers[0]["Seen"] != "" {
+		t.Error("expected options not to be modified by the method")
+	}
+}
+
+func TestFuzzOptionsDefaults(t *testing.T) {
+	inputs := FuzzOptions{}.combinations()
+	if len(inputs) != 1 || inputs[0].User.UserID != 1 || inputs[0].ItemID != "" || inputs[0].newControllerRequest().Headers == nil {
+		t.Fatal("expected a single default combination", inputs)
+	}
+}
+
+func TestAutoFuzzTestControllerWithOptions(t *testing.T) {
+	tester := &MockTestRunner{}
+	AutoFuzzTestControllerWithOptions(tester, &AuthController{}, FuzzOptions{ActionFilters: []string{"a", "b"}})
+	if len(tester.Messages) != 2 || !strings.Contains(tester.Messages[1], `ActionFilter "b"`) {
+		t.Fatal("expected a message per combination", tester.Messages)
+	}
+}
+
+type SlowController struct {
+	running, maxRunning int32
+	overlap             chan struct{} // if set, Get waits for a second call to run at the same time
+	overlapOnce         sync.Once
+}
+
+func (c *SlowController) Get(cr *ControllerRequest) (string, error) {
+	running := atomic.AddInt32(&c.running, 1)
+	defer atomic.AddInt32(&c.running, -1)
+	for {
+		max := atomic.LoadInt32(&c.maxRunning)
+		if running <= max || atomic.CompareAndSwapInt32(&c.maxRunning, max, running) {
+			break
+		}
+	}
+	if c.overlap != nil {
+		if running >= 2 {
+			c.overlapOnce.Do(func() { close(c.overlap) })
+		}
+		select {
+		case <-c.overlap:
+		case <-time.After(time.Second):
+		}
+	}
+	return `"` + cr.ItemID + `"`, nil
+}
+
+func (c *SlowController) GetHang(cr *ControllerRequest) (string, error) {
+	<-cr.Context().Done()
+	return "", cr.Context().Err()
+}
+
+func (c *SlowController) Bogus() {
+}
+
+func TestFuzzTestControllerParallel(t *testing.T) {
+	controller := &SlowController{overlap: make(chan struct{})}
+	options := FuzzOptions{ItemIDs: []string{"1", "2", "3", "4"}, Parallel: 4, Timeout: time.Second}
+	results := FuzzTestControllerWithOptions(controller, options)
+	if len(results) != 9 || results[0].MethodName != "Bogus" || results[1].ReturnData[0] != `"1"` || results[4].ReturnData[0] != `"4"` {
+		t.Fatal("expected results in method and input order", results)
+	}
+	if controller.maxRunning < 2 {
+		t.Error("expected methods to run in parallel", controller.maxRunning)
+	}
+}
+
+func TestFuzzTestControllerTimeout(t *testing.T) {
+	results := FuzzTestControllerWithOptions(&SlowController{}, FuzzOptions{Timeout: 50 * time.Millisecond})
+	hang := results[2]
+	if hang.MethodName != "GetHang" || !hang.TimedOut || hang.Duration < 50*time.Millisecond || len(hang.ReturnData) != 0 || results[1].TimedOut {
+		t.Fatal("expected GetHang to time out", results)
+	}
+	tester := &MockTestRunner{}
+	AutoFuzzTestControllerWithOptions(tester, &SlowController{}, FuzzOptions{Timeout: 50 * time.Millisecond})
+	if len(tester.Errors) != 2 || !strings.HasPrefix(tester.Errors[1], `Method "GetHang" timed out after`) {
+		t.Fatal("expected validation and timeout errors", tester.Errors)
+	}
+}
+
+func TestSummarizeFuzzResults(t *testing.T) {
+	results := FuzzTestControllerWithOptions(&SlowController{}, FuzzOptions{ItemIDs: []string{"1", "2"}, Timeout: 50 * time.Millisecond})
+	coverage := SummarizeFuzzResults(results)
+	expected := "4 calls\nGet: Get, GetHang\nTimed out: GetHang\nSkipped Bogus: Method \"Bogus\" error: Unsupported http verb: \"\"\n"
+	if coverage.String() != expected {
+		t.Fatal("unexpected coverage summary", coverage.String())
+	}
+}
+
+type RawHeaderController struct {
+}
+
+func (c *RawHeaderController) GetRaw(cr *ControllerRequest, rw http.ResponseWriter, r *http.Request) {
+	rw.Write([]byte(r.Method + " " + r.URL.Path + " " + r.Header.Get("Accept-Language")))
+}
+
+func (c *RawHeaderController) Bogus() {
+}
+
+func (c *RawHeaderController) CachePolicies() map[string]CachePolicy {
+	return map[string]CachePolicy{"GetRaw": {TTL: time.Minute}}
+}
+
+func TestFuzzTestControllerReportsErrorsOnce(t *testing.T) {
+	results := FuzzTestControllerWithOptions(&RawHeaderController{}, FuzzOptions{Headers: []map[string]string{{"Accept-Language": "en"}, {"Accept-Language": "fr"}}})
+	if len(results) != 3 || results[0].MethodName != "Bogus" || results[0].ValidationError == nil || results[0].ReturnData != nil {
+		t.Fatal("expected rejected method to be reported once", results)
+	}
+	if results[1].ValidationError == nil || results[2].ValidationError != nil {
+		t.Error("expected invalid cache policy to be reported once", results[1].ValidationError, results[2].ValidationError)
+	}
+	if results[1].ReturnData[0] != "GET /rawHeader/1/Raw en" || results[2].ReturnData[0] != "GET /rawHeader/1/Raw fr" {
+		t.Error("expected raw method to get each combination's request", results[1].ReturnData, results[2].ReturnData)
+	}
+}
+
+func TestFuzzTestControllerRecoversPanics(t *testing.T) {
+	for _, timeout := range []time.Duration{0, time.Second} {
+		results := FuzzTestControllerWithOptions(&PanicController{}, FuzzOptions{Timeout: timeout})
+		if len(results) != 1 || results[0].Panic != "boom" || results[0].TimedOut || len(results[0].ReturnData) != 0 {
+			t.Fatal("expected panic to be recorded", timeout, results)
+		}
+	}
+	tester := &MockTestRunner{}
+	AutoFuzzTestControllerWithOptions(tester, &PanicController{}, FuzzOptions{Timeout: time.Second})
+	if len(tester.Errors) != 1 || !strings.HasPrefix(tester.Errors[0], `Method "Get" panicked: boom`) {
+		t.Fatal("expected panic to be reported", tester.Errors)
+	}
+}
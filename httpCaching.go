@@ -0,0 +1,92 @@
+package oneweb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// writeCacheableResponse adds a strong ETag computed from the body, along with any Last-Modified and
+// Cache-Control values the controller set on cr, and answers conditional GETs with 304 Not Modified
+func writeCacheableResponse(rw http.ResponseWriter, r *http.Request, cr *ControllerRequest, json string) {
+	etag := computeETag(json)
+	rw.Header().Set("ETag", etag)
+	if cr.CacheControl != "" {
+		rw.Header().Set("Cache-Control", cr.CacheControl)
+	}
+	if !cr.LastModified.IsZero() {
+		rw.Header().Set("Last-Modified", cr.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if isNotModified(r, etag, cr.LastModified) {
+		writeNotModified(rw, len(json))
+		return
+	}
+	writeResponse(rw, json)
+}
+
+func computeETag(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func isNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etagListMatches(ifNoneMatch, etag, false)
+	}
+	ifModifiedSince, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !lastModified.IsZero() && !lastModified.Truncate(time.Second).After(ifModifiedSince)
+}
+
+// checkPreconditions evaluates If-Match on PUT and DELETE requests against the ETag of the response the
+// matching Get method currently returns for the same URL, so that clients can't overwrite changes they haven't seen
+func (c *ControllerRoutingHandler) checkPreconditions(r *http.Request, cr *ControllerRequest, key routeKey) error {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || (r.Method != "PUT" && r.Method != "DELETE") {
+		return nil
+	}
+	getMethod := c.getRoute(routeKey{key.controller, "Get", key.action})
+	if getMethod == nil || getMethod.raw != nil || getMethod.isStreaming() { // only a (string, error) Get has a body to hash
+		return withStatus(http.StatusPreconditionFailed, fmt.Errorf("Precondition failed: no Get method to compare If-Match against"))
+	}
+	current, err := getMethod.call("GET", cr, nil)
+	cr.LastModified, cr.CacheControl = time.Time{}, ""
+	if err != nil {
+		return withStatus(http.StatusPreconditionFailed, fmt.Errorf("Precondition failed: %s", err.Error()))
+	}
+	if !etagListMatches(ifMatch, computeETag(current), true) {
+		return withStatus(http.StatusPreconditionFailed, fmt.Errorf("Precondition failed: resource has been modified"))
+	}
+	return nil
+}
+
+// etagListMatches compares etag against a comma separated If-Match or If-None-Match header value.
+// Strong comparison (for If-Match) never matches weak validators.  The content-coding suffix added by
+// setEncodedETag is ignored since the etags being compared are always computed from the uncompressed body
+func etagListMatches(header, etag string, strong bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if strings.HasPrefix(candidate, "W/") {
+			if strong {
+				continue
+			}
+			candidate = candidate[2:]
+		}
+		if candidate == etag || stripETagEncoding(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func stripETagEncoding(etag string) string {
+	if i := strings.LastIndex(etag, "-"); i > 0 && strings.HasSuffix(etag, `"`) {
+		return etag[:i] + `"`
+	}
+	return etag
+}
@@ -0,0 +1,159 @@
+package oneweb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var testLastModified = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+type CachingController struct {
+}
+
+func (c *CachingController) Get(cr *ControllerRequest) (string, error) {
+	cr.LastModified = testLastModified
+	cr.CacheControl = "max-age=60"
+	return `{"id":1}`, nil
+}
+
+func (c *CachingController) GetMissing(cr *ControllerRequest) (string, error) {
+	return "", errors.New("not found")
+}
+
+func (c *CachingController) DeleteMissing(cr *ControllerRequest) (string, error) {
+	return "deleted", nil
+}
+
+func getCachingRouter() *ControllerRoutingHandler {
+	router := getMockRouter()
+	router.RegisterController("cached", &CachingController{})
+	return router
+}
+
+func TestETagAndControllerCacheHeaders(t *testing.T) {
+	rw := httptest.NewRecorder()
+	getCachingRouter().controllerRoutingHandler(rw, newHttpRequest("GET", "/cached/1", nil))
+	if rw.Code != 200 || rw.Header().Get("ETag") != computeETag(`{"id":1}`) || rw.Header().Get("Cache-Control") != "max-age=60" ||
+		rw.Header().Get("Last-Modified") != "Thu, 02 Jan 2020 03:04:05 GMT" || rw.Body.String() != `{"id":1}` {
+		t.Fatal("expected cache headers", rw.Code, rw.Header(), rw.Body.String())
+	}
+}
+
+func TestIfNoneMatch(t *testing.T) {
+	for header, expected := range map[string]int{
+		computeETag(`{"id":1}`):                 304,
+		`"other", W/` + computeETag(`{"id":1}`): 304,
+		"*":                                     304,
+		`"other"`:                               200,
+	} {
+		r := newHttpRequest("GET", "/cached/1", nil)
+		r.Header.Set("If-None-Match", header)
+		rw := httptest.NewRecorder()
+		getCachingRouter().controllerRoutingHandler(rw, r)
+		if rw.Code != expected || (expected == 304 && rw.Body.Len() != 0) {
+			t.Error("unexpected response for If-None-Match", header, rw.Code, rw.Body.String())
+		}
+	}
+}
+
+func TestIfModifiedSince(t *testing.T) {
+	for header, expected := range map[string]int{
+		"Thu, 02 Jan 2020 03:04:05 GMT": 304,
+		"Fri, 03 Jan 2020 00:00:00 GMT": 304,
+		"Wed, 01 Jan 2020 00:00:00 GMT": 200,
+		"bogus":                         200,
+	} {
+		r := newHttpRequest("GET", "/cached/1", nil)
+		r.Header.Set("If-Modified-Since", header)
+		rw := httptest.NewRecorder()
+		getCachingRouter().controllerRoutingHandler(rw, r)
+		if rw.Code != expected {
+			t.Error("unexpected response for If-Modified-Since", header, rw.Code)
+		}
+	}
+}
+
+func TestIfNoneMatchTakesPrecedence(t *testing.T) {
+	r := newHttpRequest("GET", "/cached/1", nil)
+	r.Header.Set("If-None-Match", `"other"`)
+	r.Header.Set("If-Modified-Since", "Fri, 03 Jan 2020 00:00:00 GMT")
+	rw := httptest.NewRecorder()
+	getCachingRouter().controllerRoutingHandler(rw, r)
+	if rw.Code != 200 {
+		t.Fatal("expected If-Modified-Since to be ignored when If-None-Match is present", rw.Code)
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	for header, expected := range map[string]int{
+		computeETag("called Get"):        200,
+		"*":                              200,
+		`"stale"`:                        412,
+		"W/" + computeETag("called Get"): 412,
+	} {
+		r := newHttpRequest("PUT", "/projects/123", ioutil.NopCloser(bytes.NewBufferString(`{ "hello": "there" }`)))
+		r.Header.Set("If-Match", header)
+		rw := httptest.NewRecorder()
+		getMockRouter().controllerRoutingHandler(rw, r)
+		if rw.Code != expected {
+			t.Error("unexpected response for If-Match", header, rw.Code, rw.Body.String())
+		}
+	}
+}
+
+func TestIfMatchWithoutGetMethod(t *testing.T) {
+	r := newHttpRequest("PUT", "/projects/123/valid", ioutil.NopCloser(bytes.NewBufferString(`[]`)))
+	r.Header.Set("If-Match", "*")
+	rw := httptest.NewRecorder()
+	getMockRouter().controllerRoutingHandler(rw, r)
+	if rw.Code != 412 || getErrorMessage(rw) != "Precondition failed: no Get method to compare If-Match against" {
+		t.Fatal("expected precondition failure", rw.Code, rw.Body.String())
+	}
+}
+
+type StreamingGetController struct {
+}
+
+func (c *StreamingGetController) GetExport(cr *ControllerRequest, stream *ResponseStream) error {
+	return stream.Encode(1)
+}
+
+func (c *StreamingGetController) DeleteExport(cr *ControllerRequest) (string, error) {
+	return "deleted", nil
+}
+
+func TestIfMatchWithStreamingGetMethod(t *testing.T) {
+	router := getMockRouter()
+	router.RegisterController("streams", &StreamingGetController{})
+	r := newHttpRequest("DELETE", "/streams/1/export", nil)
+	r.Header.Set("If-Match", "*")
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, r)
+	if rw.Code != 412 || getErrorMessage(rw) != "Precondition failed: no Get method to compare If-Match against" {
+		t.Fatal("expected precondition failure", rw.Code, rw.Body.String())
+	}
+}
+
+func TestIfMatchGetError(t *testing.T) {
+	r := newHttpRequest("DELETE", "/cached/123/missing", nil)
+	r.Header.Set("If-Match", "*")
+	rw := httptest.NewRecorder()
+	getCachingRouter().controllerRoutingHandler(rw, r)
+	if rw.Code != 412 || getErrorMessage(rw) != "Precondition failed: not found" {
+		t.Fatal("expected precondition failure", rw.Code, rw.Body.String())
+	}
+}
+
+func TestGetErrorStatus(t *testing.T) {
+	if status := getErrorStatus(withStatus(412, nil)); status != 412 {
+		t.Error("expected 412", status)
+	}
+	if status := getErrorStatus(bytes.ErrTooLarge); status != 500 {
+		t.Error("expected 500", status)
+	}
+}
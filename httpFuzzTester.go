@@ -0,0 +1,148 @@
+package oneweb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	httpFuzzController = "fuzz"
+	httpFuzzItemID     = "1"
+)
+
+// RouteTestResult is the response to one generated request sent through a ControllerRoutingHandler
+type RouteTestResult struct {
+	MethodName    string
+	HTTPMethod    string
+	URL           string
+	RequestBody   string
+	MalformedBody bool // the request deliberately sent invalid JSON, so an error response is expected
+	StatusCode    int
+	ResponseBody  string
+	InvalidJSON   bool   // a successful application/json response which doesn't parse
+	HandlerError  string // error logged by the handler, or the recovered panic
+	Panicked      bool
+}
+
+func AutoHTTPFuzzTestController(t TestRunner, controller interface{}) {
+	results, err := HTTPFuzzTestController(controller)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, result := range results {
+		switch {
+		case result.Panicked:
+			t.Error(fmt.Sprintf("%s %s panicked: %s", result.HTTPMethod, result.URL, result.HandlerError))
+		case result.MalformedBody && result.StatusCode < 400:
+			t.Error(fmt.Sprintf("%s %s accepted a malformed body with status %d", result.HTTPMethod, result.URL, result.StatusCode))
+		case result.InvalidJSON:
+			t.Error(fmt.Sprintf("%s %s returned invalid JSON: %s", result.HTTPMethod, result.URL, result.ResponseBody))
+		case !result.MalformedBody && result.HandlerError != "":
+			t.Error(fmt.Sprintf("%s %s returned %d: %s", result.HTTPMethod, result.URL, result.StatusCode, result.HandlerError))
+		default:
+			t.Logf("%s %s returned %d: %v", result.HTTPMethod, result.URL, result.StatusCode, result.ResponseBody)
+		}
+	}
+}
+
+// HTTPFuzzTestController registers the controller on a new ControllerRoutingHandler and sends every route a request
+// with a zero value body.  Routes which read JSON are also sent a malformed body.  The error is from registration
+func HTTPFuzzTestController(controller interface{}) ([]RouteTestResult, error) {
+	logger := &fuzzLogger{}
+	router := NewControllerRoutingHandler()
+	router.Logger = logger
+	var registerErr error
+	if err := router.RegisterController(httpFuzzController, controller); err.Error() != "" {
+		registerErr = err
+	}
+
+	routes := router.loadTable().routes
+	keys := make([]routeKey, 0, len(routes))
+	for key := range routes {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return routes[keys[i]].methodName < routes[keys[j]].methodName })
+
+	var results []RouteTestResult
+	for _, key := range keys {
+		rt := routes[key]
+		httpMethod, url := getRouteRequest(httpFuzzController, rt, httpFuzzItemID)
+		results = append(results, sendFuzzRequest(router, logger, rt, httpMethod, url, getFuzzBody(rt), false))
+		if rt.bodyType != nil {
+			results = append(results, sendFuzzRequest(router, logger, rt, httpMethod, url, "{", true))
+		}
+	}
+	return results, registerErr
+}
+
+// getRouteRequest returns the HTTP method and path which the router maps to rt
+func getRouteRequest(controllerName string, rt *route, itemID string) (string, string) {
+	httpMethod := strings.ToUpper(rt.httpVerb)
+	if rt.httpVerb == "Index" {
+		return "GET", "/" + controllerName
+	}
+	if rt.httpVerb == "Post" && rt.urlAction == "" {
+		return httpMethod, "/" + controllerName
+	}
+	url := "/" + controllerName + "/" + itemID
+	if rt.urlAction != "" {
+		url += "/" + rt.urlAction
+	}
+	return httpMethod, url
+}
+
+func getFuzzBody(rt *route) string {
+	switch {
+	case rt.bodyType != nil && isSlice(rt.bodyType):
+		return "[]"
+	case rt.bodyType != nil:
+		body, _ := json.Marshal(reflect.New(rt.bodyType.Elem()).Interface())
+		return string(body)
+	case rt.raw != nil && (rt.httpVerb == "Post" || rt.httpVerb == "Put"):
+		return "{}"
+	}
+	return ""
+}
+
+func sendFuzzRequest(router *ControllerRoutingHandler, logger *fuzzLogger, rt *route, httpMethod, url, body string, malformed bool) (result RouteTestResult) {
+	result = RouteTestResult{MethodName: rt.methodName, HTTPMethod: httpMethod, URL: url, RequestBody: body, MalformedBody: malformed}
+	ctx, cancel := context.WithTimeout(context.Background(), fuzzEventTimeout) // ends event streams
+	defer cancel()
+	r := httptest.NewRequest(httpMethod, url, strings.NewReader(body)).WithContext(ctx)
+	r.Header.Set("X-User", `{"UserID":1}`)
+	rw := httptest.NewRecorder()
+	logger.lastError = ""
+	defer func() {
+		if p := recover(); p != nil {
+			result.Panicked = true
+			result.HandlerError = fmt.Sprint(p)
+		}
+	}()
+	router.controllerRoutingHandler(rw, r)
+
+	result.StatusCode = rw.Code
+	result.ResponseBody = rw.Body.String()
+	result.HandlerError = logger.lastError
+	result.InvalidJSON = rw.Code < 300 && strings.HasPrefix(rw.Header().Get("Content-Type"), "application/json") &&
+		!json.Valid(rw.Body.Bytes())
+	return result
+}
+
+// fuzzLogger keeps the error from the latest access log entry
+type fuzzLogger struct {
+	lastError string
+}
+
+func (l *fuzzLogger) Log(ctx context.Context, level slog.Level, msg string, args ...interface{}) {
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] == "error" {
+			l.lastError = fmt.Sprint(args[i+1])
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package oneweb
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type HTTPFuzzController struct {
+}
+
+func (c *HTTPFuzzController) Index(cr *ControllerRequest) (string, error) {
+	return "[]", nil
+}
+
+func (c *HTTPFuzzController) GetReport(cr *ControllerRequest) (string, error) {
+	return "not json", nil
+}
+
+func (c *HTTPFuzzController) GetFailure(cr *ControllerRequest) (string, error) {
+	return "", errors.New("failed")
+}
+
+func (c *HTTPFuzzController) Put(cr *ControllerRequest, data *SimpleData) (string, error) {
+	return `{"Hello":"` + data.Hello + `"}`, nil
+}
+
+func (c *HTTPFuzzController) PostCrash(cr *ControllerRequest, data []SimpleData) (string, error) {
+	var m map[string]string
+	m["boom"] = "" // nil map
+	return "", nil
+}
+
+func (c *HTTPFuzzController) GetInvalid() (string, error) {
+	return "", nil
+}
+
+func TestHTTPFuzzTestController(t *testing.T) {
+	results, err := HTTPFuzzTestController(&HTTPFuzzController{})
+	if err == nil || err.Error() != "Method \"GetInvalid\" error: Requires 1 input arg (cr *ControllerRequest)\n" {
+		t.Error("expected registration error", err)
+	}
+	if len(results) != 7 {
+		t.Fatal("expected a request per route and a malformed request per JSON body route", results)
+	}
+	failure, report, index := results[0], results[1], results[2]
+	if failure.URL != "/fuzz/1/Failure" || failure.StatusCode != 500 || failure.HandlerError != "Internal error calling controller method: failed" {
+		t.Error("expected handler error", failure)
+	}
+	if report.HTTPMethod != "GET" || !report.InvalidJSON || index.URL != "/fuzz" || index.InvalidJSON || index.StatusCode != 200 {
+		t.Error("expected invalid JSON to be reported", report, index)
+	}
+	crash, malformedCrash := results[3], results[4]
+	if crash.URL != "/fuzz/1/Crash" || crash.RequestBody != "[]" || !crash.Panicked || !malformedCrash.MalformedBody || malformedCrash.Panicked {
+		t.Error("expected panic to be recovered", crash, malformedCrash)
+	}
+	put, malformedPut := results[5], results[6]
+	if put.HTTPMethod != "PUT" || put.RequestBody != `{"Hello":""}` || put.ResponseBody != `{"Hello":""}` || malformedPut.StatusCode != 500 {
+		t.Error("expected zero value and malformed bodies", put, malformedPut)
+	}
+}
+
+func TestAutoHTTPFuzzTestController(t *testing.T) {
+	tester := &MockTestRunner{}
+	AutoHTTPFuzzTestController(tester, &HTTPFuzzController{})
+	if len(tester.Errors) != 4 || len(tester.Messages) != 4 {
+		t.Fatal("expected registration, handler, invalid JSON and panic errors", tester.Errors, tester.Messages)
+	}
+}
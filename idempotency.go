@@ -0,0 +1,206 @@
+package oneweb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	defaultIdempotencyTTL   = 24 * time.Hour
+	maxIdempotencyKeyLength = 255
+)
+
+// unreplayedHeaders belong to the request being answered rather than to the stored response
+var unreplayedHeaders = map[string]bool{RequestIDHeader: true, "Vary": true, "Content-Encoding": true, "Content-Length": true,
+	"Ratelimit-Limit": true, "Ratelimit-Remaining": true, "Ratelimit-Reset": true}
+
+// IdempotencyRecord is the first response to a POST with a given Idempotency-Key.  Completed is false
+// while that request is still being processed
+type IdempotencyRecord struct {
+	RequestHash string
+	Completed   bool
+	Status      int
+	Header      http.Header
+	Body        []byte
+}
+
+// IdempotencyStore remembers responses by key.  Implementations shared between servers must make Begin atomic
+type IdempotencyStore interface {
+	// Begin returns the existing record for key, or reserves key with record and returns false
+	Begin(key string, record IdempotencyRecord, ttl time.Duration) (IdempotencyRecord, bool)
+	// Complete replaces the reservation with the finished response
+	Complete(key string, record IdempotencyRecord, ttl time.Duration)
+	// Release removes a reservation so that the request can be retried
+	Release(key string)
+}
+
+// callIdempotent replays the stored response for a repeated Idempotency-Key, or calls the method and stores its response.
+// Server errors and panics aren't stored, so the client can retry with the same key.  Keys are scoped to the user, or
+// to the client IP for anonymous requests
+func (c *ControllerRoutingHandler) callIdempotent(rw http.ResponseWriter, r *http.Request, cr *ControllerRequest, key routeKey, method *route) error {
+	idempotencyKey := r.Header.Get(IdempotencyKeyHeader)
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		return withStatus(http.StatusBadRequest, errors.New("Idempotency-Key is too long"))
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errors.Wrap(err, "Failed to read request body")
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	requestHash := getRequestHash(r, body)
+
+	storeKey := key.controller + "\x00" + RateLimitByUser(r, cr) + "\x00" + idempotencyKey
+	ttl := c.idempotencyTTL()
+	if existing, ok := c.Idempotency.Begin(storeKey, IdempotencyRecord{RequestHash: requestHash}, ttl); ok {
+		switch {
+		case existing.RequestHash != requestHash:
+			return withStatus(http.StatusUnprocessableEntity, errors.New("Idempotency-Key was already used for a different request"))
+		case !existing.Completed:
+			return withStatus(http.StatusConflict, errors.New("A request with this Idempotency-Key is still being processed"))
+		}
+		replayResponse(rw, existing)
+		return nil
+	}
+
+	completed := false
+	defer func() { // also runs when the method panics, which would otherwise leave the key reserved for the whole ttl
+		if !completed {
+			c.Idempotency.Release(storeKey)
+		}
+	}()
+	recorder := &responseRecorder{ResponseWriter: rw}
+	err = c.callMethod(recorder, r, cr, key, method)
+	if err != nil && recorder.status == 0 {
+		writeError(recorder, getErrorStatus(err), err, cr.RequestID) // written here so that the error response is stored too
+	}
+	if recorder.status >= 500 {
+		return err
+	}
+	c.Idempotency.Complete(storeKey, IdempotencyRecord{requestHash, true, recorder.status, recorder.header, recorder.body.Bytes()}, ttl)
+	completed = true
+	return err
+}
+
+func (c *ControllerRoutingHandler) idempotencyTTL() time.Duration {
+	if c.IdempotencyTTL <= 0 {
+		return defaultIdempotencyTTL
+	}
+	return c.IdempotencyTTL
+}
+
+func getRequestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.URL.RequestURI() + "\x00"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replayResponse(rw http.ResponseWriter, record IdempotencyRecord) {
+	header := rw.Header()
+	for name, values := range record.Header {
+		header[name] = values
+	}
+	header.Set("Idempotent-Replayed", "true")
+	rw.WriteHeader(record.Status)
+	rw.Write(record.Body)
+}
+
+// responseRecorder keeps a copy of the status, headers and body written through it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+		w.header = make(http.Header)
+		for name, values := range w.ResponseWriter.Header() {
+			if !unreplayedHeaders[name] {
+				w.header[name] = append([]string(nil), values...)
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// MemoryIdempotencyStore keeps records in memory until they expire
+type MemoryIdempotencyStore struct {
+	lock      sync.Mutex
+	records   map[string]memoryIdempotencyEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	record  IdempotencyRecord
+	expires time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]memoryIdempotencyEntry), now: time.Now}
+}
+
+func (m *MemoryIdempotencyStore) Begin(key string, record IdempotencyRecord, ttl time.Duration) (IdempotencyRecord, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := m.now()
+	if now.Sub(m.lastSweep) >= ttl {
+		m.sweep(now)
+	}
+	if entry, ok := m.records[key]; ok && now.Before(entry.expires) {
+		return entry.record, true
+	}
+	m.records[key] = memoryIdempotencyEntry{record, now.Add(ttl)}
+	return IdempotencyRecord{}, false
+}
+
+func (m *MemoryIdempotencyStore) Complete(key string, record IdempotencyRecord, ttl time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.records[key] = memoryIdempotencyEntry{record, m.now().Add(ttl)}
+}
+
+func (m *MemoryIdempotencyStore) Release(key string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.records, key)
+}
+
+func (m *MemoryIdempotencyStore) Len() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.records)
+}
+
+func (m *MemoryIdempotencyStore) sweep(now time.Time) {
+	m.lastSweep = now
+	for key, entry := range m.records {
+		if !now.Before(entry.expires) {
+			delete(m.records, key)
+		}
+	}
+}
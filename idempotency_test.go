@@ -0,0 +1,145 @@
+package oneweb
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type OrderController struct {
+	created int
+}
+
+func (c *OrderController) Post(cr *ControllerRequest, data *SimpleData) (string, error) {
+	if data.Hello == "crash" {
+		return "", errors.New("database went away")
+	}
+	if data.Hello == "panic" {
+		panic("boom")
+	}
+	c.created++
+	return `{"id":` + strconv.Itoa(c.created) + `}`, nil
+}
+
+func (c *OrderController) PostReject(cr *ControllerRequest, rw http.ResponseWriter, r *http.Request) {
+	rw.WriteHeader(400)
+	io.WriteString(rw, "rejected")
+}
+
+func getOrderRouter() (*ControllerRoutingHandler, *OrderController) {
+	router := getMockRouter()
+	router.Idempotency = NewMemoryIdempotencyStore()
+	controller := &OrderController{}
+	router.RegisterController("orders", controller)
+	return router, controller
+}
+
+func postOrder(router *ControllerRoutingHandler, key, user, body string) *httptest.ResponseRecorder {
+	return postOrderURL(router, "/orders", key, user, body)
+}
+
+func postOrderURL(router *ControllerRoutingHandler, url, key, user, body string) *httptest.ResponseRecorder {
+	r := newHttpRequest("POST", url, ioutil.NopCloser(bytes.NewBufferString(body)))
+	r.Header.Set(IdempotencyKeyHeader, key)
+	r.Header.Set("X-User", user)
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, r)
+	return rw
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	router, controller := getOrderRouter()
+	first := postOrder(router, "abc", `{"UserID":1}`, `{"Hello":"world"}`)
+	replay := postOrder(router, "abc", `{"UserID":1}`, `{"Hello":"world"}`)
+	if controller.created != 1 || replay.Code != 200 || replay.Body.String() != `{"id":1}` || replay.Header().Get("Idempotent-Replayed") != "true" ||
+		replay.Header().Get("Content-Type") != "application/json" || replay.Header().Get(RequestIDHeader) == first.Header().Get(RequestIDHeader) {
+		t.Fatal("expected stored response to be replayed", controller.created, replay.Code, replay.Body.String(), replay.Header())
+	}
+	if other := postOrder(router, "abc", `{"UserID":2}`, `{"Hello":"world"}`); other.Body.String() != `{"id":2}` {
+		t.Fatal("expected keys to be scoped per user", other.Body.String())
+	}
+	if noKey := postOrder(router, "", `{"UserID":1}`, `{"Hello":"world"}`); noKey.Body.String() != `{"id":3}` {
+		t.Fatal("expected requests without a key to be processed", noKey.Body.String())
+	}
+}
+
+func TestIdempotencyMismatchedBody(t *testing.T) {
+	router, _ := getOrderRouter()
+	postOrder(router, "abc", `{"UserID":1}`, `{"Hello":"world"}`)
+	rw := postOrder(router, "abc", `{"UserID":1}`, `{"Hello":"there"}`)
+	if rw.Code != 422 || getErrorMessage(rw) != "Idempotency-Key was already used for a different request" {
+		t.Fatal("expected 422 for a reused key", rw.Code, rw.Body.String())
+	}
+}
+
+func TestIdempotencyStoresClientErrorsOnly(t *testing.T) {
+	router, _ := getOrderRouter()
+	postOrderURL(router, "/orders/1/reject", "bad", `{"UserID":1}`, `{}`)
+	if rw := postOrderURL(router, "/orders/1/reject", "bad", `{"UserID":1}`, `{}`); rw.Code != 400 || rw.Header().Get("Idempotent-Replayed") != "true" || rw.Body.String() != "rejected" {
+		t.Fatal("expected client error to be replayed", rw.Code, rw.Body.String())
+	}
+	postOrder(router, "crash", `{"UserID":1}`, `{"Hello":"crash"}`)
+	if rw := postOrder(router, "crash", `{"UserID":1}`, `{"Hello":"crash"}`); rw.Code != 500 || rw.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatal("expected server error to be retried", rw.Code)
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	router, _ := getOrderRouter()
+	router.Idempotency.Begin("Orders\x00user:1\x00abc", IdempotencyRecord{RequestHash: getRequestHash(newHttpRequest("POST", "/orders", nil), []byte(`{}`))}, time.Minute)
+	if rw := postOrder(router, "abc", `{"UserID":1}`, `{}`); rw.Code != 409 {
+		t.Fatal("expected conflict while the first request is in progress", rw.Code, rw.Body.String())
+	}
+}
+
+func TestIdempotencyReleasesKeyOnPanic(t *testing.T) {
+	router, _ := getOrderRouter()
+	func() {
+		defer func() { recover() }()
+		postOrder(router, "abc", `{"UserID":1}`, `{"Hello":"panic"}`)
+	}()
+	if store := router.Idempotency.(*MemoryIdempotencyStore); store.Len() != 0 {
+		t.Fatal("expected reservation to be released after a panic", store.Len())
+	}
+}
+
+func TestIdempotencyAnonymousScopedByIP(t *testing.T) {
+	router, _ := getOrderRouter()
+	post := func(remoteAddr string) *httptest.ResponseRecorder {
+		r := newHttpRequest("POST", "/orders", ioutil.NopCloser(bytes.NewBufferString(`{"Hello":"world"}`)))
+		r.Header.Set(IdempotencyKeyHeader, "abc")
+		r.RemoteAddr = remoteAddr
+		rw := httptest.NewRecorder()
+		router.controllerRoutingHandler(rw, r)
+		return rw
+	}
+	post("10.0.0.1:1000")
+	if rw := post("10.0.0.2:1000"); rw.Body.String() != `{"id":2}` || rw.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatal("expected anonymous clients not to share keys", rw.Body.String())
+	}
+	if rw := post("10.0.0.1:2000"); rw.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatal("expected the same anonymous client to get the stored response", rw.Body.String())
+	}
+}
+
+func TestMemoryIdempotencyStoreExpires(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryIdempotencyStore()
+	store.now = func() time.Time { return now }
+	store.Begin("a", IdempotencyRecord{}, time.Minute)
+	store.Complete("a", IdempotencyRecord{Completed: true}, time.Minute)
+	if record, ok := store.Begin("a", IdempotencyRecord{}, time.Minute); !ok || !record.Completed {
+		t.Fatal("expected stored record", record)
+	}
+	now = now.Add(time.Minute)
+	if _, ok := store.Begin("b", IdempotencyRecord{}, time.Minute); ok || store.Len() != 1 {
+		t.Fatal("expected expired record to be swept", store.Len())
+	}
+}
@@ -0,0 +1,90 @@
+package oneweb
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Logger receives one structured entry per request.  *slog.Logger satisfies it
+type Logger interface {
+	Log(ctx context.Context, level slog.Level, msg string, args ...interface{})
+}
+
+// LogLevels sets the level access log entries are written at
+type LogLevels struct {
+	Success     slog.Level
+	ClientError slog.Level // 4xx responses
+	ServerError slog.Level // 5xx responses and requests which returned an error
+}
+
+var DefaultLogLevels = LogLevels{Success: slog.LevelInfo, ClientError: slog.LevelWarn, ServerError: slog.LevelError}
+
+var DefaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-User"}
+
+func (c *ControllerRoutingHandler) logger() Logger {
+	if c.Logger == nil {
+		return slog.Default()
+	}
+	return c.Logger
+}
+
+func (c *ControllerRoutingHandler) logRequest(r *http.Request, cr *ControllerRequest, methodName string, sw *statusWriter, duration time.Duration, err error) {
+	args := []interface{}{
+		"request_id", cr.RequestID,
+		"http_method", r.Method,
+		"path", r.URL.Path,
+		"controller", cr.ControllerName,
+		"method", methodName,
+		"item_id", cr.ItemID,
+		"user_id", cr.User.UserID,
+		"status", sw.Status(),
+		"bytes", sw.bytes,
+		"duration", duration,
+	}
+	if err != nil {
+		args = append(args, "error", err.Error())
+	}
+	if c.LogHeaders {
+		args = append(args, slog.Group("headers", c.redactedHeaders(cr.Headers)...))
+	}
+	c.logger().Log(r.Context(), c.getLogLevel(sw.Status(), err), "request", args...)
+}
+
+func (c *ControllerRoutingHandler) getLogLevel(status int, err error) slog.Level {
+	switch {
+	case err != nil || status >= 500:
+		return c.LogLevels.ServerError
+	case status >= 400:
+		return c.LogLevels.ClientError
+	}
+	return c.LogLevels.Success
+}
+
+func (c *ControllerRoutingHandler) redactedHeaders(headers map[string]string) []interface{} {
+	args := make([]interface{}, 0, len(headers)*2)
+	for key, value := range headers {
+		if c.isRedactedHeader(key) {
+			value = "[REDACTED]"
+		}
+		args = append(args, key, value)
+	}
+	return args
+}
+
+func (c *ControllerRoutingHandler) isRedactedHeader(key string) bool {
+	for _, redacted := range c.RedactHeaders {
+		if http.CanonicalHeaderKey(redacted) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func getLoggedMethodName(key routeKey, method *route) string {
+	if method != nil {
+		return method.methodName
+	}
+	return key.verb + key.action
+}
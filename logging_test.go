@@ -0,0 +1,114 @@
+package oneweb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type logEntry struct {
+	level slog.Level
+	msg   string
+	args  []interface{}
+}
+
+type MockLogger struct {
+	Entries []logEntry
+}
+
+func (l *MockLogger) Log(ctx context.Context, level slog.Level, msg string, args ...interface{}) {
+	l.Entries = append(l.Entries, logEntry{level, msg, args})
+}
+
+func (e logEntry) arg(key string) interface{} {
+	for i := 0; i+1 < len(e.args); i += 2 {
+		if e.args[i] == key {
+			return e.args[i+1]
+		}
+	}
+	return nil
+}
+
+func TestLogRequestSuccess(t *testing.T) {
+	logger := &MockLogger{}
+	router := getMockRouter()
+	router.Logger = logger
+	router.controllerRoutingHandler(httptest.NewRecorder(), newHttpRequest("GET", "/projects/123/method", nil))
+	if len(logger.Entries) != 1 {
+		t.Fatal("expected 1 log entry", logger.Entries)
+	}
+	entry := logger.Entries[0]
+	if entry.level != slog.LevelInfo || entry.msg != "request" || entry.arg("controller") != "Projects" || entry.arg("method") != "GetMethod" ||
+		entry.arg("item_id") != "123" || entry.arg("status") != 200 || entry.arg("bytes") != len("called GetMethod") || entry.arg("error") != nil {
+		t.Fatal("unexpected access log entry", entry)
+	}
+}
+
+func TestLogRequestError(t *testing.T) {
+	logger := &MockLogger{}
+	router := getMockRouter()
+	router.Logger = logger
+	router.controllerRoutingHandler(httptest.NewRecorder(), newHttpRequest("GET", "/projects/1/error", nil))
+	entry := logger.Entries[0]
+	if entry.level != slog.LevelError || entry.arg("status") != 500 || entry.arg("error") != "Internal error calling controller method: failed" {
+		t.Fatal("unexpected error log entry", entry)
+	}
+}
+
+func TestLogRequestMethodNotFound(t *testing.T) {
+	logger := &MockLogger{}
+	router := getMockRouter()
+	router.Logger = logger
+	router.controllerRoutingHandler(httptest.NewRecorder(), newHttpRequest("GET", "/projects/123/bogus", nil))
+	if entry := logger.Entries[0]; entry.arg("method") != "GetBogus" || entry.arg("error") != "Method \"GetBogus\" not found" {
+		t.Fatal("unexpected not found log entry", entry)
+	}
+}
+
+func TestLogRequestWithSlogRedactsHeaders(t *testing.T) {
+	buf := &bytes.Buffer{}
+	router := getMockRouter()
+	router.Logger = slog.New(slog.NewJSONHandler(buf, nil))
+	router.LogHeaders = true
+	r := newHttpRequest("GET", "/projects/123/method", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Accept", "application/json")
+	router.controllerRoutingHandler(httptest.NewRecorder(), r)
+
+	var entry struct {
+		Level   string
+		Status  int
+		Headers map[string]string
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal("expected JSON log line", err, buf.String())
+	}
+	if entry.Level != "INFO" || entry.Status != 200 || entry.Headers["Authorization"] != "[REDACTED]" ||
+		entry.Headers["X-User"] != "[REDACTED]" || entry.Headers["Accept"] != "application/json" || strings.Contains(buf.String(), "secret") {
+		t.Fatal("expected redacted headers", buf.String())
+	}
+}
+
+func TestGetLogLevel(t *testing.T) {
+	router := NewControllerRoutingHandler()
+	router.LogLevels.Success = slog.LevelDebug
+	for _, test := range []struct {
+		status   int
+		err      error
+		expected slog.Level
+	}{
+		{200, nil, slog.LevelDebug},
+		{404, nil, slog.LevelWarn},
+		{500, nil, slog.LevelError},
+		{200, fmt.Errorf("failed"), slog.LevelError},
+	} {
+		if level := router.getLogLevel(test.status, test.err); level != test.expected {
+			t.Error("unexpected level", test, level)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package oneweb
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics receives per-request measurements labelled by controller and method name.  Requests which don't
+// match a route are reported with empty labels so that arbitrary URLs can't create new series
+type Metrics interface {
+	RequestStarted(controller, method string)
+	RequestFinished(controller, method string, status int, duration time.Duration)
+}
+
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// MetricsRegistry is an in-memory Metrics implementation which serves the Prometheus text exposition format
+type MetricsRegistry struct {
+	buckets []float64
+	lock    sync.Mutex
+	methods map[metricLabels]*methodMetrics
+}
+
+type metricLabels struct {
+	controller string
+	method     string
+}
+
+type methodMetrics struct {
+	inFlight     int
+	requests     uint64
+	errors       map[int]uint64
+	bucketCounts []uint64
+	latencySum   float64
+}
+
+// NewMetricsRegistry creates a registry using the given latency histogram buckets (in seconds), or DefaultLatencyBuckets if none are given
+func NewMetricsRegistry(buckets ...float64) *MetricsRegistry {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &MetricsRegistry{buckets: buckets, methods: make(map[metricLabels]*methodMetrics)}
+}
+
+func (m *MetricsRegistry) RequestStarted(controller, method string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.get(controller, method).inFlight++
+}
+
+func (m *MetricsRegistry) RequestFinished(controller, method string, status int, duration time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	metrics := m.get(controller, method)
+	metrics.inFlight--
+	metrics.requests++
+	if status >= 400 {
+		metrics.errors[status]++
+	}
+	seconds := duration.Seconds()
+	metrics.latencySum += seconds
+	for i, bucket := range m.buckets {
+		if seconds <= bucket {
+			metrics.bucketCounts[i]++
+		}
+	}
+}
+
+func (m *MetricsRegistry) get(controller, method string) *methodMetrics {
+	labels := metricLabels{controller, method}
+	metrics, ok := m.methods[labels]
+	if !ok {
+		metrics = &methodMetrics{errors: make(map[int]uint64), bucketCounts: make([]uint64, len(m.buckets))}
+		m.methods[labels] = metrics
+	}
+	return metrics
+}
+
+// Handler serves the registry in the Prometheus text exposition format, e.g. for mounting on /metrics
+func (m *MetricsRegistry) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WriteTo(rw)
+	})
+}
+
+func (m *MetricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	labels := make([]metricLabels, 0, len(m.methods))
+	for label := range m.methods {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].controller != labels[j].controller {
+			return labels[i].controller < labels[j].controller
+		}
+		return labels[i].method < labels[j].method
+	})
+
+	b := &strings.Builder{}
+	b.WriteString("# HELP oneweb_requests_total Requests handled by controller method.\n# TYPE oneweb_requests_total counter\n")
+	for _, label := range labels {
+		fmt.Fprintf(b, "oneweb_requests_total{%s} %d\n", label, m.methods[label].requests)
+	}
+	b.WriteString("# HELP oneweb_request_errors_total Requests which returned a 4xx or 5xx status.\n# TYPE oneweb_request_errors_total counter\n")
+	for _, label := range labels {
+		errors := m.methods[label].errors
+		statuses := make([]int, 0, len(errors))
+		for status := range errors {
+			statuses = append(statuses, status)
+		}
+		sort.Ints(statuses)
+		for _, status := range statuses {
+			fmt.Fprintf(b, "oneweb_request_errors_total{%s,status=\"%d\"} %d\n", label, status, errors[status])
+		}
+	}
+	b.WriteString("# HELP oneweb_request_duration_seconds Request latency by controller method.\n# TYPE oneweb_request_duration_seconds histogram\n")
+	for _, label := range labels {
+		metrics := m.methods[label]
+		for i, bucket := range m.buckets {
+			fmt.Fprintf(b, "oneweb_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n", label, strconv.FormatFloat(bucket, 'g', -1, 64), metrics.bucketCounts[i])
+		}
+		fmt.Fprintf(b, "oneweb_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", label, metrics.requests)
+		fmt.Fprintf(b, "oneweb_request_duration_seconds_sum{%s} %s\n", label, strconv.FormatFloat(metrics.latencySum, 'g', -1, 64))
+		fmt.Fprintf(b, "oneweb_request_duration_seconds_count{%s} %d\n", label, metrics.requests)
+	}
+	b.WriteString("# HELP oneweb_requests_in_flight Requests currently being handled by controller method.\n# TYPE oneweb_requests_in_flight gauge\n")
+	for _, label := range labels {
+		fmt.Fprintf(b, "oneweb_requests_in_flight{%s} %d\n", label, m.methods[label].inFlight)
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (l metricLabels) String() string {
+	return fmt.Sprintf("controller=\"%s\",method=\"%s\"", escapeLabelValue(l.controller), escapeLabelValue(l.method))
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) RequestStarted(controller, method string) {}
+
+func (noopMetrics) RequestFinished(controller, method string, status int, duration time.Duration) {}
+
+func (c *ControllerRoutingHandler) metrics() Metrics {
+	if c.Metrics == nil {
+		return noopMetrics{}
+	}
+	return c.Metrics
+}
+
+func getMetricLabels(key routeKey, method *route) (string, string) {
+	if method == nil {
+		return "", ""
+	}
+	return key.controller, method.methodName
+}
@@ -0,0 +1,90 @@
+package oneweb
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsRegistry(t *testing.T) {
+	metrics := NewMetricsRegistry(0.1, 0.01)
+	metrics.RequestStarted("Projects", "GetMethod")
+	metrics.RequestFinished("Projects", "GetMethod", 200, 5*time.Millisecond)
+	metrics.RequestStarted("Projects", "GetMethod")
+	metrics.RequestFinished("Projects", "GetMethod", 500, 50*time.Millisecond)
+	metrics.RequestStarted("Projects", "Index")
+
+	rw := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rw, newHttpRequest("GET", "/metrics", nil))
+	body := rw.Body.String()
+	for _, expected := range []string{
+		`oneweb_requests_total{controller="Projects",method="GetMethod"} 2`,
+		`oneweb_request_errors_total{controller="Projects",method="GetMethod",status="500"} 1`,
+		`oneweb_request_duration_seconds_bucket{controller="Projects",method="GetMethod",le="0.01"} 1`,
+		`oneweb_request_duration_seconds_bucket{controller="Projects",method="GetMethod",le="0.1"} 2`,
+		`oneweb_request_duration_seconds_bucket{controller="Projects",method="GetMethod",le="+Inf"} 2`,
+		`oneweb_request_duration_seconds_sum{controller="Projects",method="GetMethod"} 0.055`,
+		`oneweb_request_duration_seconds_count{controller="Projects",method="GetMethod"} 2`,
+		`oneweb_requests_in_flight{controller="Projects",method="GetMethod"} 0`,
+		`oneweb_requests_in_flight{controller="Projects",method="Index"} 1`,
+		"# TYPE oneweb_request_duration_seconds histogram",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Error("expected metrics output to contain", expected, body)
+		}
+	}
+	if !strings.HasPrefix(rw.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Error("expected text exposition content type", rw.Header())
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if escaped := escapeLabelValue("a\"b\\c\nd"); escaped != `a\"b\\c\nd` {
+		t.Fatal("unexpected escaping", escaped)
+	}
+}
+
+func TestRouterRecordsMetrics(t *testing.T) {
+	metrics := NewMetricsRegistry()
+	router := getMockRouter()
+	router.Metrics = metrics
+	router.controllerRoutingHandler(httptest.NewRecorder(), newHttpRequest("GET", "/projects/1/error", nil))
+	router.controllerRoutingHandler(httptest.NewRecorder(), newHttpRequest("GET", "/projects/123/bogus", nil))
+	if m := metrics.methods[metricLabels{"Projects", "GetError"}]; m == nil || m.requests != 1 || m.errors[500] != 1 || m.inFlight != 0 {
+		t.Fatal("expected failed GetError request to be recorded", m)
+	}
+	if m := metrics.methods[metricLabels{"", ""}]; m == nil || m.requests != 1 {
+		t.Fatal("expected unmatched request to be recorded without labels", m)
+	}
+}
+
+type PanicController struct {
+}
+
+func (c *PanicController) Get(cr *ControllerRequest) (string, error) {
+	panic("boom")
+}
+
+func TestRouterRecordsPanics(t *testing.T) {
+	metrics := NewMetricsRegistry()
+	logger := &fuzzLogger{}
+	router := getMockRouter()
+	router.Metrics = metrics
+	router.Logger = logger
+	router.RegisterController("panics", &PanicController{})
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Error("expected the panic to reach net/http", p)
+			}
+		}()
+		router.controllerRoutingHandler(httptest.NewRecorder(), newHttpRequest("GET", "/panics/1", nil))
+	}()
+	if m := metrics.methods[metricLabels{"Panics", "Get"}]; m == nil || m.requests != 1 || m.errors[500] != 1 || m.inFlight != 0 {
+		t.Fatal("expected panicking request to be finished", m)
+	}
+	if logger.lastError != "Panic calling controller method: boom" {
+		t.Fatal("expected panic to be logged", logger.lastError)
+	}
+}
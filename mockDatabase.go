@@ -0,0 +1,346 @@
+package oneweb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// MockAnyArg matches any value of a statement argument
+var MockAnyArg = mockAnyArg{}
+
+type mockAnyArg struct{}
+
+// MockDatabase is a fake database/sql driver for controller tests.  Statements are answered by the first
+// expectation, in the order they were added, whose query and args match.  Statements without a matching
+// expectation fail with an error instead of panicking so controllers can be fuzzed without a live database.
+// It is safe for concurrent use, e.g. by FuzzTestControllerWithOptions with Parallel set
+type MockDatabase struct {
+	lock         sync.Mutex
+	expectations []*MockExpectation
+	statements   []MockStatement
+}
+
+// MockExpectation scripts the response to a query or exec.  By default it answers any number of statements
+type MockExpectation struct {
+	db           *MockDatabase
+	query        string
+	exec         bool
+	args         []driver.Value
+	matchArgs    bool
+	columns      []string
+	rows         [][]driver.Value
+	lastInsertID int64
+	rowsAffected int64
+	err          error
+	times        int
+	calls        int
+}
+
+// MockStatement is a statement run against a MockDatabase.  Transactions are logged as BEGIN, COMMIT and ROLLBACK
+type MockStatement struct {
+	Query string
+	Args  []interface{}
+	Err   error // error returned to the caller, either injected or because no expectation matched
+}
+
+// NewMockDatabase returns a *sql.DB for the controller under test and the MockDatabase which scripts it
+func NewMockDatabase() (*sql.DB, *MockDatabase) {
+	m := &MockDatabase{}
+	return sql.OpenDB(m), m
+}
+
+// ExpectQuery adds an expectation for a query.  Whitespace in query is not significant
+func (m *MockDatabase) ExpectQuery(query string) *MockExpectation {
+	return m.expect(query, false)
+}
+
+// ExpectExec adds an expectation for an insert, update, delete or other statement which returns no rows
+func (m *MockDatabase) ExpectExec(query string) *MockExpectation {
+	return m.expect(query, true)
+}
+
+func (m *MockDatabase) expect(query string, exec bool) *MockExpectation {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	e := &MockExpectation{db: m, query: normalizeQuery(query), exec: exec}
+	m.expectations = append(m.expectations, e)
+	return e
+}
+
+// Statements returns every statement run so far, in order
+func (m *MockDatabase) Statements() []MockStatement {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return append([]MockStatement(nil), m.statements...)
+}
+
+// ExpectationsWereMet reports expectations which were never used, or used fewer than Times, and statements
+// which didn't match any expectation
+func (m *MockDatabase) ExpectationsWereMet() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	var errMsg string
+	for _, e := range m.expectations {
+		if e.calls == 0 || e.calls < e.times {
+			errMsg += fmt.Sprintf("Expectation \"%s\" error: called %d times\n", e.query, e.calls)
+		}
+	}
+	for _, s := range m.statements {
+		if s.Err != nil && errors.Cause(s.Err) == errUnexpectedStatement {
+			errMsg += s.Err.Error() + "\n"
+		}
+	}
+	if errMsg != "" {
+		return errors.New(errMsg)
+	}
+	return nil
+}
+
+// WithArgs matches only statements with these args.  Use MockAnyArg for args which may have any value
+func (e *MockExpectation) WithArgs(args ...interface{}) *MockExpectation {
+	e.db.lock.Lock()
+	defer e.db.lock.Unlock()
+	e.args = convertMockValues(args)
+	e.matchArgs = true
+	return e
+}
+
+// WillReturnRows sets the result of a query.  Each row has one value per column
+func (e *MockExpectation) WillReturnRows(columns []string, rows ...[]interface{}) *MockExpectation {
+	e.db.lock.Lock()
+	defer e.db.lock.Unlock()
+	e.columns = columns
+	e.rows = nil
+	for _, row := range rows {
+		e.rows = append(e.rows, convertMockValues(row))
+	}
+	return e
+}
+
+// WillReturnResult sets the result of an exec
+func (e *MockExpectation) WillReturnResult(lastInsertID, rowsAffected int64) *MockExpectation {
+	e.db.lock.Lock()
+	defer e.db.lock.Unlock()
+	e.lastInsertID = lastInsertID
+	e.rowsAffected = rowsAffected
+	return e
+}
+
+// WillReturnError makes matching statements fail with err
+func (e *MockExpectation) WillReturnError(err error) *MockExpectation {
+	e.db.lock.Lock()
+	defer e.db.lock.Unlock()
+	e.err = err
+	return e
+}
+
+// Times limits the expectation to answering n statements
+func (e *MockExpectation) Times(n int) *MockExpectation {
+	e.db.lock.Lock()
+	defer e.db.lock.Unlock()
+	e.times = n
+	return e
+}
+
+var errUnexpectedStatement = errors.New("unexpected statement")
+
+// run logs the statement and returns the matching expectation's result and rows.  They are copied while the lock
+// is held because the expectation can still be changed by its setters
+func (m *MockDatabase) run(query string, args []driver.Value, exec bool) (mockResult, *mockRows, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	statement := MockStatement{Query: query, Args: make([]interface{}, len(args))}
+	for i, arg := range args {
+		statement.Args[i] = arg
+	}
+	var result mockResult
+	rows := &mockRows{}
+	e := m.match(normalizeQuery(query), args, exec)
+	if e == nil {
+		statement.Err = errors.Wrapf(errUnexpectedStatement, "Statement \"%s\" with args %v error", query, statement.Args)
+	} else {
+		e.calls++
+		statement.Err = e.err
+		result = mockResult{e.lastInsertID, e.rowsAffected}
+		rows.columns = append([]string(nil), e.columns...)
+		rows.rows = append([][]driver.Value(nil), e.rows...)
+	}
+	m.statements = append(m.statements, statement)
+	return result, rows, statement.Err
+}
+
+func (m *MockDatabase) match(query string, args []driver.Value, exec bool) *MockExpectation {
+	for _, e := range m.expectations {
+		if e.exec != exec || e.query != query || (e.times > 0 && e.calls >= e.times) {
+			continue
+		}
+		if e.matchArgs && !mockArgsMatch(e.args, args) {
+			continue
+		}
+		return e
+	}
+	return nil
+}
+
+func (m *MockDatabase) log(query string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.statements = append(m.statements, MockStatement{Query: query})
+}
+
+func mockArgsMatch(expected, actual []driver.Value) bool {
+	if len(expected) != len(actual) {
+		return false
+	}
+	for i := range expected {
+		if _, ok := expected[i].(mockAnyArg); ok {
+			continue
+		}
+		if !reflect.DeepEqual(expected[i], actual[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// convertMockValues converts values the way database/sql converts args, so that expected ints match the int64 args passed to the driver
+func convertMockValues(values []interface{}) []driver.Value {
+	converted := make([]driver.Value, len(values))
+	for i, value := range values {
+		if _, ok := value.(mockAnyArg); ok {
+			converted[i] = value
+			continue
+		}
+		v, err := driver.DefaultParameterConverter.ConvertValue(value)
+		if err != nil {
+			v = value
+		}
+		converted[i] = v
+	}
+	return converted
+}
+
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// MockDatabase is its own driver.Connector so that no driver has to be registered globally
+func (m *MockDatabase) Connect(ctx context.Context) (driver.Conn, error) {
+	return &mockConn{m}, nil
+}
+
+func (m *MockDatabase) Driver() driver.Driver {
+	return mockDriver{m}
+}
+
+type mockDriver struct {
+	db *MockDatabase
+}
+
+func (d mockDriver) Open(name string) (driver.Conn, error) {
+	return &mockConn{d.db}, nil
+}
+
+type mockConn struct {
+	db *MockDatabase
+}
+
+func (c *mockConn) Prepare(query string) (driver.Stmt, error) {
+	return &mockStmt{c.db, query}, nil
+}
+
+func (c *mockConn) Close() error {
+	return nil
+}
+
+func (c *mockConn) Begin() (driver.Tx, error) {
+	c.db.log("BEGIN")
+	return &mockTx{c.db}, nil
+}
+
+type mockTx struct {
+	db *MockDatabase
+}
+
+func (t *mockTx) Commit() error {
+	t.db.log("COMMIT")
+	return nil
+}
+
+func (t *mockTx) Rollback() error {
+	t.db.log("ROLLBACK")
+	return nil
+}
+
+type mockStmt struct {
+	db    *MockDatabase
+	query string
+}
+
+func (s *mockStmt) Close() error {
+	return nil
+}
+
+func (s *mockStmt) NumInput() int {
+	return -1
+}
+
+func (s *mockStmt) Exec(args []driver.Value) (driver.Result, error) {
+	result, _, err := s.db.run(s.query, args, true)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *mockStmt) Query(args []driver.Value) (driver.Rows, error) {
+	_, rows, err := s.db.run(s.query, args, false)
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+type mockResult struct {
+	lastInsertID int64
+	rowsAffected int64
+}
+
+func (r mockResult) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r mockResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+type mockRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *mockRows) Columns() []string {
+	return r.columns
+}
+
+func (r *mockRows) Close() error {
+	return nil
+}
+
+func (r *mockRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,161 @@
+package oneweb
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type ProjectDBController struct {
+	db *sql.DB
+}
+
+func (c *ProjectDBController) Get(cr *ControllerRequest) (string, error) {
+	var name string
+	if err := c.db.QueryRow("SELECT name FROM projects WHERE id = ?", cr.ItemID).Scan(&name); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`{"name":%q}`, name), nil
+}
+
+func (c *ProjectDBController) Index(cr *ControllerRequest) (string, error) {
+	rows, err := c.db.Query("SELECT id, name FROM projects WHERE owner = ?", cr.User.UserID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return "", err
+		}
+		count++
+	}
+	return fmt.Sprintf(`{"count":%d}`, count), rows.Err()
+}
+
+func TestMockDatabaseQuery(t *testing.T) {
+	db, mock := NewMockDatabase()
+	mock.ExpectQuery("SELECT id, name FROM projects WHERE owner = ?").WithArgs(1).
+		WillReturnRows([]string{"id", "name"}, []interface{}{1, "first"}, []interface{}{2, "second"})
+
+	c := &ProjectDBController{db}
+	cr := &ControllerRequest{User: &User{UserID: 1}}
+	if out, err := c.Index(cr); err != nil || out != `{"count":2}` {
+		t.Fatal("expected scripted rows", out, err)
+	}
+	cr.User.UserID = 2
+	if _, err := c.Index(cr); err == nil {
+		t.Fatal("expected error for unmatched args")
+	}
+	if err := mock.ExpectationsWereMet(); err == nil {
+		t.Fatal("expected unexpected statement to be reported")
+	}
+
+	statements := mock.Statements()
+	if len(statements) != 2 || statements[0].Args[0] != int64(1) || statements[0].Err != nil || statements[1].Err == nil {
+		t.Fatal("expected both statements to be logged", statements)
+	}
+}
+
+func TestMockDatabaseWhitespaceAndAnyArg(t *testing.T) {
+	db, mock := NewMockDatabase()
+	mock.ExpectQuery("SELECT name\n  FROM projects\n  WHERE id = ?").WithArgs(MockAnyArg).WillReturnRows([]string{"name"}, []interface{}{"any"})
+
+	c := &ProjectDBController{db}
+	if out, err := c.Get(&ControllerRequest{ItemID: "42"}); err != nil || out != `{"name":"any"}` {
+		t.Fatal("expected query to match", out, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal("expected expectations to be met", err)
+	}
+}
+
+func TestMockDatabaseErrorsAndTimes(t *testing.T) {
+	db, mock := NewMockDatabase()
+	failure := errors.New("connection reset")
+	mock.ExpectExec("UPDATE projects SET name = ?").Times(1).WillReturnResult(0, 3)
+	mock.ExpectExec("UPDATE projects SET name = ?").WillReturnError(failure)
+	mock.ExpectExec("DELETE FROM projects").Times(2)
+
+	if result, err := db.Exec("UPDATE projects SET name = ?", "new"); err != nil {
+		t.Fatal("expected first update to succeed", err)
+	} else if n, _ := result.RowsAffected(); n != 3 {
+		t.Fatal("expected 3 rows affected", n)
+	}
+	if _, err := db.Exec("UPDATE projects SET name = ?", "new"); err != failure {
+		t.Fatal("expected injected error", err)
+	}
+	if err := mock.ExpectationsWereMet(); err == nil || err.Error() != "Expectation \"DELETE FROM projects\" error: called 0 times\n" {
+		t.Fatal("expected unused expectation to be reported", err)
+	}
+}
+
+func TestMockDatabaseTransaction(t *testing.T) {
+	db, mock := NewMockDatabase()
+	mock.ExpectExec("INSERT INTO projects (name) VALUES (?)").WillReturnResult(7, 1)
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := tx.Exec("INSERT INTO projects (name) VALUES (?)", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := result.LastInsertId(); id != 7 {
+		t.Fatal("expected last insert id", id)
+	}
+	tx.Commit()
+
+	statements := mock.Statements()
+	if len(statements) != 3 || statements[0].Query != "BEGIN" || statements[2].Query != "COMMIT" {
+		t.Fatal("expected transaction to be logged", statements)
+	}
+}
+
+func TestMockDatabaseFuzzTestController(t *testing.T) {
+	db, mock := NewMockDatabase()
+	mock.ExpectQuery("SELECT name FROM projects WHERE id = ?").WithArgs("1").WillReturnRows([]string{"name"}, []interface{}{"first"})
+	mock.ExpectQuery("SELECT name FROM projects WHERE id = ?").WithArgs("0").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT id, name FROM projects WHERE owner = ?").WithArgs(MockAnyArg).WillReturnRows([]string{"id", "name"})
+
+	results := FuzzTestControllerWithOptions(&ProjectDBController{db}, FuzzOptions{ItemIDs: []string{"1", "0", "abc"}, Parallel: 4})
+	if len(results) != 6 {
+		t.Fatal("expected Get and Index to be fuzzed with each input", len(results))
+	}
+	for _, result := range results {
+		if result.ReturnData == nil {
+			t.Fatal("expected method to be called", result)
+		}
+	}
+	if results[0].ReturnData[0] != `{"name":"first"}` || results[1].ReturnData[1] != sql.ErrNoRows || results[2].ReturnData[1] == nil {
+		t.Fatal("expected scripted results", results)
+	}
+	if len(mock.Statements()) != 6 {
+		t.Fatal("expected every call to be logged", mock.Statements())
+	}
+}
+
+func TestMockDatabaseScriptWhileQuerying(t *testing.T) {
+	db, mock := NewMockDatabase()
+	expectation := mock.ExpectQuery("SELECT name FROM projects WHERE id = ?").WillReturnRows([]string{"name"}, []interface{}{"first"})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			expectation.WithArgs(MockAnyArg).WillReturnRows([]string{"name"}, []interface{}{"second"}).Times(0)
+		}
+	}()
+	c := &ProjectDBController{db}
+	for i := 0; i < 50; i++ {
+		if out, err := c.Get(&ControllerRequest{ItemID: "1"}); err != nil || (out != `{"name":"first"}` && out != `{"name":"second"}`) {
+			t.Fatal("expected scripted rows", out, err)
+		}
+	}
+	<-done
+}
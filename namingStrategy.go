@@ -0,0 +1,88 @@
+package oneweb
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy maps Go controller and action names to URL path segments
+type NamingStrategy interface {
+	URLName(goName string) string       // converts a Go identifier (e.g. UserProfile) into its URL segment
+	Normalize(urlSegment string) string // canonicalizes a URL segment before it is matched against a route
+}
+
+var (
+	// LegacyNaming matches segments case-insensitively against the Go name (e.g. /users/1/userprofile)
+	LegacyNaming NamingStrategy = &namingStrategy{urlName: func(name string) string { return name }, normalize: titleCase}
+	// KebabCaseNaming maps UserProfile to user-profile
+	KebabCaseNaming NamingStrategy = &namingStrategy{urlName: separatedName("-"), normalize: strings.ToLower}
+	// SnakeCaseNaming maps UserProfile to user_profile
+	SnakeCaseNaming NamingStrategy = &namingStrategy{urlName: separatedName("_"), normalize: strings.ToLower}
+	// CamelCaseNaming maps UserProfile to userProfile and matches it case-sensitively
+	CamelCaseNaming NamingStrategy = &namingStrategy{urlName: camelCaseName, normalize: func(segment string) string { return segment }}
+)
+
+// RouteOverrider is implemented by controllers which need explicit URL segments for some methods.
+// RouteOverrides returns a map of Go method name (e.g. GetUserProfile) to URL action segment (e.g. profile)
+type RouteOverrider interface {
+	RouteOverrides() map[string]string
+}
+
+type namingStrategy struct {
+	urlName   func(string) string
+	normalize func(string) string
+}
+
+func (n *namingStrategy) URLName(goName string) string {
+	return n.urlName(goName)
+}
+
+func (n *namingStrategy) Normalize(urlSegment string) string {
+	return n.normalize(urlSegment)
+}
+
+func titleCase(name string) string {
+	return strings.Title(strings.ToLower(name))
+}
+
+func separatedName(separator string) func(string) string {
+	return func(name string) string {
+		return strings.ToLower(strings.Join(splitWords(name), separator))
+	}
+}
+
+func camelCaseName(name string) string {
+	words := splitWords(name)
+	for i := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(words[i])
+		} else {
+			words[i] = titleCase(words[i])
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// splitWords splits a Go identifier into words, keeping acronyms together (HTMLPage => HTML, Page)
+func splitWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case runes[i] == '_' || runes[i] == '-':
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+		case i > start && unicode.IsUpper(runes[i]) &&
+			(!unicode.IsUpper(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))):
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+	return words
+}
@@ -0,0 +1,128 @@
+package oneweb
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type NamingController struct {
+}
+
+func (c *NamingController) GetUserProfile(cr *ControllerRequest) (string, error) {
+	return "called GetUserProfile", nil
+}
+
+func (c *NamingController) GetHTMLPage(cr *ControllerRequest) (string, error) {
+	return "called GetHTMLPage", nil
+}
+
+func (c *NamingController) GetLastLogin(cr *ControllerRequest) (string, error) {
+	return "called GetLastLogin", nil
+}
+
+func (c *NamingController) RouteOverrides() map[string]string {
+	return map[string]string{"GetLastLogin": "seen"}
+}
+
+type CollidingController struct {
+}
+
+func (c *CollidingController) GetUserProfile(cr *ControllerRequest) (string, error) {
+	return "", nil
+}
+
+func (c *CollidingController) GetUser_Profile(cr *ControllerRequest) (string, error) {
+	return "", nil
+}
+
+func (c *CollidingController) RouteOverrides() map[string]string {
+	return map[string]string{"GetMissing": "missing"}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := map[string][]string{
+		"UserProfile":   {"User", "Profile"},
+		"HTMLPage":      {"HTML", "Page"},
+		"UserID":        {"User", "ID"},
+		"user_profile":  {"user", "profile"},
+		"Version2Notes": {"Version2", "Notes"},
+		"":              nil,
+	}
+	for name, expected := range tests {
+		if words := splitWords(name); !reflect.DeepEqual(words, expected) {
+			t.Error("unexpected words for", name, words)
+		}
+	}
+}
+
+func TestNamingStrategies(t *testing.T) {
+	if name := KebabCaseNaming.URLName("HTMLPage"); name != "html-page" {
+		t.Error("expected html-page", name)
+	}
+	if name := SnakeCaseNaming.URLName("UserProfile"); name != "user_profile" {
+		t.Error("expected user_profile", name)
+	}
+	if name := CamelCaseNaming.URLName("UserProfile"); name != "userProfile" {
+		t.Error("expected userProfile", name)
+	}
+	if name := LegacyNaming.Normalize("userProfile"); name != "Userprofile" {
+		t.Error("expected Userprofile", name)
+	}
+	if name := CamelCaseNaming.Normalize("userProfile"); name != "userProfile" {
+		t.Error("expected case to be preserved", name)
+	}
+}
+
+func TestRegisterControllerKebabCase(t *testing.T) {
+	router := NewControllerRoutingHandler()
+	router.Naming = KebabCaseNaming
+	router.RegisterController("UserAccounts", &NamingController{})
+	for path, expected := range map[string]string{
+		"/user-accounts/1/user-profile": "called GetUserProfile",
+		"/User-Accounts/1/HTML-page":    "called GetHTMLPage",
+		"/user-accounts/1/seen":         "called GetLastLogin",
+	} {
+		rw := httptest.NewRecorder()
+		router.controllerRoutingHandler(rw, newHttpRequest("GET", path, nil))
+		if body := rw.Body.String(); body != expected {
+			t.Error("unexpected response for", path, body)
+		}
+	}
+}
+
+func TestRegisterControllerCamelCaseIsCaseSensitive(t *testing.T) {
+	router := getMockRouter()
+	router.Naming = CamelCaseNaming
+	router.RegisterController("users", &NamingController{})
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, newHttpRequest("GET", "/users/1/userProfile", nil))
+	if body := rw.Body.String(); body != "called GetUserProfile" {
+		t.Fatal("expected to call GetUserProfile", body)
+	}
+	rw = httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, newHttpRequest("GET", "/users/1/userprofile", nil))
+	if rw.Code != 500 {
+		t.Fatal("expected case-sensitive match to fail", rw.Body.String())
+	}
+}
+
+func TestRegisterControllerLegacyNamingIgnoresOverrideMethod(t *testing.T) {
+	router := NewControllerRoutingHandler()
+	err := router.RegisterController("users", &NamingController{})
+	if err.Error() != "" || len(router.loadTable().routes) != 3 || router.getRoute(routeKey{"Users", "Get", "Userprofile"}) == nil || router.getRoute(routeKey{"Users", "Get", "Seen"}) == nil {
+		t.Fatal("expected 3 methods registered with legacy names", err, router.loadTable().routes)
+	}
+}
+
+func TestRegisterControllerCollisions(t *testing.T) {
+	router := NewControllerRoutingHandler()
+	router.Naming = KebabCaseNaming
+	err := router.RegisterController("users", &CollidingController{})
+	expectedErr := `Method "GetUser_Profile" error: URL path collides with method "GetUserProfile"
+Method "GetMissing" error: Route override for unknown method
+`
+	if err.Error() != expectedErr || len(router.loadTable().routes) != 1 {
+		t.Fatal("expected collision and unknown override errors", err)
+	}
+}
@@ -0,0 +1,128 @@
+package oneweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// FuzzTargetOptions seeds and checks a native fuzz target created by FuzzControllerMethod
+type FuzzTargetOptions struct {
+	ItemIDs []string      // seed ItemIDs, "1" if not set
+	Bodies  []interface{} // seed request bodies, marshalled to JSON.  The zero value of the body type if not set
+	User    *User         // user 1 if not set
+	// Check reports invariant violations for a call which didn't panic
+	Check func(t testing.TB, cr *ControllerRequest, body []byte, response string, err error)
+}
+
+// FuzzControllerMethod turns a controller method into a target for go test -fuzz:
+//
+//	func FuzzProjectsPut(f *testing.F) {
+//		oneweb.FuzzControllerMethod(f, &ProjectsController{}, "Put", oneweb.FuzzTargetOptions{Bodies: []interface{}{Project{Name: "a"}}})
+//	}
+//
+// The fuzzer mutates the ItemID and the request body.  Bodies which don't decode into the method's body type are
+// skipped, since the router rejects them before calling the method.  Panics, invalid JSON from methods returning
+// (string, error) without an error, and failed Checks fail the target
+func FuzzControllerMethod(f *testing.F, controller interface{}, methodName string, options FuzzTargetOptions) {
+	f.Helper()
+	rt, err := getFuzzTargetRoute(controller, methodName)
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, seed := range getFuzzSeeds(rt, options) {
+		f.Add(seed.itemID, seed.body)
+	}
+	f.Fuzz(func(t *testing.T, itemID string, body []byte) {
+		runFuzzTarget(t, rt, options, itemID, body)
+	})
+}
+
+func getFuzzTargetRoute(controller interface{}, methodName string) (*route, error) {
+	method := reflect.ValueOf(controller).MethodByName(methodName)
+	httpVerb, _, err := validateMethod(method, methodName)
+	if err != nil {
+		return nil, err
+	}
+	rt := compileRoute(method, methodName, httpVerb)
+	if rt.isStreaming() {
+		return nil, fmt.Errorf("Method \"%s\" error: Streaming methods can't be fuzz targets", methodName)
+	}
+	return rt, nil
+}
+
+type fuzzSeed struct {
+	itemID string
+	body   []byte
+}
+
+func getFuzzSeeds(rt *route, options FuzzTargetOptions) []fuzzSeed {
+	itemIDs := options.ItemIDs
+	if len(itemIDs) == 0 {
+		itemIDs = []string{"1"}
+	}
+	var bodies [][]byte
+	for _, body := range options.Bodies {
+		data, _ := json.Marshal(body)
+		bodies = append(bodies, data)
+	}
+	if len(bodies) == 0 {
+		bodies = [][]byte{[]byte(getFuzzBody(rt))}
+	}
+	var seeds []fuzzSeed
+	for _, itemID := range itemIDs {
+		for _, body := range bodies {
+			seeds = append(seeds, fuzzSeed{itemID, body})
+		}
+	}
+	return seeds
+}
+
+func runFuzzTarget(t testing.TB, rt *route, options FuzzTargetOptions, itemID string, body []byte) {
+	t.Helper()
+	httpMethod := strings.ToUpper(rt.httpVerb)
+	if rt.httpVerb == "Index" {
+		httpMethod = "GET"
+	}
+	r := &http.Request{Method: httpMethod, URL: &url.URL{Path: "/"}, Header: make(http.Header), Body: ioutil.NopCloser(bytes.NewReader(body))}
+	data, err := getJSONBody(r, rt)
+	if err != nil {
+		t.Skip("body doesn't decode into the method's body type")
+		return
+	}
+	user := options.User
+	if user == nil {
+		user = &User{UserID: 1}
+	}
+	cr := FuzzInput{User: user, ItemID: itemID}.newControllerRequest()
+
+	defer func() {
+		if p := recover(); p != nil {
+			t.Errorf("Method \"%s\" panicked with ItemID %q and body %q: %v", rt.methodName, itemID, body, p)
+		}
+	}()
+	var response string
+	if rt.raw != nil {
+		rw := httptest.NewRecorder()
+		rt.raw(cr, rw, r)
+		response = rw.Body.String()
+		if strings.HasPrefix(rw.Header().Get("Content-Type"), "application/json") && !json.Valid(rw.Body.Bytes()) {
+			t.Errorf("Method \"%s\" wrote invalid JSON with ItemID %q and body %q: %s", rt.methodName, itemID, body, response)
+		}
+	} else {
+		response, err = rt.call(httpMethod, cr, data)
+		if err == nil && !json.Valid([]byte(response)) {
+			t.Errorf("Method \"%s\" returned invalid JSON with ItemID %q and body %q: %s", rt.methodName, itemID, body, response)
+		}
+	}
+	if options.Check != nil {
+		options.Check(t, cr, body, response, err)
+	}
+}
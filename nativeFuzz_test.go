@@ -0,0 +1,91 @@
+package oneweb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type NativeFuzzController struct {
+}
+
+func (c *NativeFuzzController) Put(cr *ControllerRequest, data *SimpleData) (string, error) {
+	output, err := json.Marshal(map[string]string{"id": cr.ItemID, "hello": data.Hello})
+	return string(output), err
+}
+
+func (c *NativeFuzzController) PutUnescaped(cr *ControllerRequest, data *SimpleData) (string, error) {
+	if strings.HasPrefix(data.Hello, "panic") {
+		panic("boom")
+	}
+	return `{"hello":"` + data.Hello + `"}`, nil
+}
+
+func FuzzNativeFuzzControllerPut(f *testing.F) {
+	FuzzControllerMethod(f, &NativeFuzzController{}, "Put", FuzzTargetOptions{
+		ItemIDs: []string{"1", "abc"},
+		Bodies:  []interface{}{SimpleData{"world"}, SimpleData{`"quoted"`}},
+		Check: func(t testing.TB, cr *ControllerRequest, body []byte, response string, err error) {
+			if !strings.Contains(response, `"id":`) {
+				t.Error("expected id in response", response)
+			}
+		},
+	})
+}
+
+// fakeTB records failures instead of stopping the test
+type fakeTB struct {
+	testing.TB
+	errors  []string
+	skipped bool
+}
+
+func (t *fakeTB) Helper() {}
+
+func (t *fakeTB) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeTB) Error(args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprint(args...))
+}
+
+func (t *fakeTB) Skip(args ...interface{}) {
+	t.skipped = true
+}
+
+func TestRunFuzzTarget(t *testing.T) {
+	rt, _ := getFuzzTargetRoute(&NativeFuzzController{}, "PutUnescaped")
+	for body, expected := range map[string]string{
+		`{"Hello":"ok"}`:      "",
+		`{"Hello":"a\"b"}`:    `Method "PutUnescaped" returned invalid JSON with ItemID "1" and body "{\"Hello\":\"a\\\"b\"}": {"hello":"a"b"}`,
+		`{"Hello":"panic!"}`:  `Method "PutUnescaped" panicked with ItemID "1" and body "{\"Hello\":\"panic!\"}": boom`,
+		`{"Hello":`:           "skipped",
+		`{"Hello":["array"]}`: "skipped",
+	} {
+		tb := &fakeTB{}
+		runFuzzTarget(tb, rt, FuzzTargetOptions{}, "1", []byte(body))
+		if (expected == "skipped") != tb.skipped || (expected != "skipped" && expected != "" && (len(tb.errors) != 1 || tb.errors[0] != expected)) ||
+			(expected == "" && len(tb.errors) != 0) {
+			t.Error("unexpected fuzz target result", body, tb.errors, tb.skipped)
+		}
+	}
+}
+
+func TestGetFuzzTargetRouteErrors(t *testing.T) {
+	if _, err := getFuzzTargetRoute(&StreamController{}, "GetExport"); err == nil || err.Error() != "Method \"GetExport\" error: Streaming methods can't be fuzz targets" {
+		t.Error("expected streaming methods to be rejected", err)
+	}
+	if _, err := getFuzzTargetRoute(&MockController{}, "GetBogus"); err == nil {
+		t.Error("expected invalid method to be rejected")
+	}
+}
+
+func TestGetFuzzSeeds(t *testing.T) {
+	rt, _ := getFuzzTargetRoute(&NativeFuzzController{}, "Put")
+	seeds := getFuzzSeeds(rt, FuzzTargetOptions{})
+	if len(seeds) != 1 || seeds[0].itemID != "1" || string(seeds[0].body) != `{"Hello":""}` {
+		t.Error("expected zero value seed", seeds)
+	}
+}
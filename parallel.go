@@ -0,0 +1,21 @@
+package oneweb
+
+import (
+	"sync"
+)
+
+// runParallel calls fn with each index from 0 to n-1, no more than parallel at a time, and waits for them all
+func runParallel(n, parallel int, fn func(i int)) {
+	running := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		running <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-running }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
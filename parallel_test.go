@@ -0,0 +1,32 @@
+package oneweb
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunParallel(t *testing.T) {
+	var running, maxRunning int32
+	results := make([]int, 10)
+	runParallel(len(results), 3, func(i int) {
+		now := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if now <= max || atomic.CompareAndSwapInt32(&maxRunning, max, now) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		results[i] = i * 2
+	})
+	for i, result := range results {
+		if result != i*2 {
+			t.Fatal("expected every index to be run", results)
+		}
+	}
+	if maxRunning < 2 || maxRunning > 3 {
+		t.Error("expected up to 3 calls at a time", maxRunning)
+	}
+}
@@ -0,0 +1,201 @@
+package oneweb
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// controllerRateLimit is the RateLimits key for a limit shared by every method of the controller
+const controllerRateLimit = "*"
+
+const rateLimitSweepInterval = time.Minute
+
+// RateLimit is a token bucket allowing Requests per Period on average, with bursts of up to Burst requests
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int          // Requests if not set
+	KeyBy    RateLimitKey // RateLimitByUser if not set
+}
+
+// RateLimitKey chooses which bucket a request is counted against
+type RateLimitKey func(r *http.Request, cr *ControllerRequest) string
+
+// RateLimitByUser counts anonymous requests (UserID 0) by client IP, so one anonymous client can't use up
+// the quota of all the others
+func RateLimitByUser(r *http.Request, cr *ControllerRequest) string {
+	if cr.User == nil || cr.User.UserID == 0 {
+		return RateLimitByIP(r, cr)
+	}
+	return "user:" + strconv.Itoa(cr.User.UserID)
+}
+
+func RateLimitByIP(r *http.Request, cr *ControllerRequest) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// RateLimiter is implemented by controllers which limit how often their methods can be called.
+// RateLimits returns a map of Go method name (e.g. GetReport) to the method's limit.  The "*" key
+// sets a quota shared by all methods of the controller which don't have their own limit
+type RateLimiter interface {
+	RateLimits() map[string]RateLimit
+}
+
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request will be allowed, when Allowed is false
+}
+
+// RateLimitStore holds the token buckets.  Implementations shared between servers must take a token atomically
+type RateLimitStore interface {
+	Take(key string, limit RateLimit) RateLimitResult
+}
+
+func getRateLimits(controller interface{}) map[string]RateLimit {
+	if limiter, ok := controller.(RateLimiter); ok {
+		return limiter.RateLimits()
+	}
+	return nil
+}
+
+func (limit RateLimit) burst() int {
+	if limit.Burst <= 0 {
+		return limit.Requests
+	}
+	return limit.Burst
+}
+
+// perSecond is the rate at which tokens are added back to the bucket
+func (limit RateLimit) perSecond() float64 {
+	return float64(limit.Requests) / limit.Period.Seconds()
+}
+
+func validateRateLimit(limit RateLimit) error {
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		return errors.New("Rate limit must allow at least one request per period")
+	}
+	return nil
+}
+
+// setRateLimit applies the method's own limit, falling back to the controller's shared quota
+func (rt *route) setRateLimit(rateLimits map[string]RateLimit, methodName string) error {
+	name := methodName
+	limit, ok := rateLimits[name]
+	if !ok {
+		name = controllerRateLimit
+		if limit, ok = rateLimits[name]; !ok {
+			return nil
+		}
+	}
+	if err := validateRateLimit(limit); err != nil {
+		return err
+	}
+	rt.rateLimit, rt.rateLimitName = &limit, name
+	return nil
+}
+
+// checkRateLimit takes a token for the request, setting RateLimit-* headers and returning a 429 error once the bucket is empty
+func (c *ControllerRoutingHandler) checkRateLimit(rw http.ResponseWriter, r *http.Request, cr *ControllerRequest, key routeKey, method *route) error {
+	if c.RateLimits == nil || method.rateLimit == nil {
+		return nil
+	}
+	keyBy := method.rateLimit.KeyBy
+	if keyBy == nil {
+		keyBy = RateLimitByUser
+	}
+	result := c.RateLimits.Take(key.controller+"\x00"+method.rateLimitName+"\x00"+keyBy(r, cr), *method.rateLimit)
+	header := rw.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(method.rateLimit.burst()))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	if !result.Allowed {
+		header.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+		return withStatus(http.StatusTooManyRequests, errors.New("Rate limit exceeded"))
+	}
+	return nil
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// MemoryRateLimitStore keeps token buckets in memory, dropping buckets which have refilled completely
+type MemoryRateLimitStore struct {
+	lock      sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	limit   RateLimit
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*tokenBucket), now: time.Now}
+}
+
+func (m *MemoryRateLimitStore) Take(key string, limit RateLimit) RateLimitResult {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	now := m.now()
+	if now.Sub(m.lastSweep) >= rateLimitSweepInterval {
+		m.sweep(now)
+	}
+	bucket, ok := m.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(limit.burst()), updated: now}
+		m.buckets[key] = bucket
+	}
+	bucket.limit = limit
+	bucket.refill(now)
+
+	result := RateLimitResult{Allowed: bucket.tokens >= 1}
+	if result.Allowed {
+		bucket.tokens--
+	} else {
+		result.RetryAfter = seconds((1 - bucket.tokens) / limit.perSecond())
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = seconds((float64(limit.burst()) - bucket.tokens) / limit.perSecond())
+	return result
+}
+
+func (m *MemoryRateLimitStore) Len() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return len(m.buckets)
+}
+
+// sweep removes buckets which are full, since a new bucket would behave the same
+func (m *MemoryRateLimitStore) sweep(now time.Time) {
+	m.lastSweep = now
+	for key, bucket := range m.buckets {
+		if bucket.refill(now); bucket.tokens >= float64(bucket.limit.burst()) {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(float64(b.limit.burst()), b.tokens+now.Sub(b.updated).Seconds()*b.limit.perSecond())
+	b.updated = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
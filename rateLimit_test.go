@@ -0,0 +1,143 @@
+package oneweb
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type LimitedController struct {
+}
+
+func (c *LimitedController) Index(cr *ControllerRequest) (string, error) {
+	return "[]", nil
+}
+
+func (c *LimitedController) Get(cr *ControllerRequest) (string, error) {
+	return "{}", nil
+}
+
+func (c *LimitedController) GetExpensive(cr *ControllerRequest) (string, error) {
+	return "{}", nil
+}
+
+func (c *LimitedController) RateLimits() map[string]RateLimit {
+	return map[string]RateLimit{"*": {Requests: 3, Period: time.Minute}, "GetExpensive": {Requests: 1, Period: time.Minute, KeyBy: RateLimitByIP}}
+}
+
+type BadLimitController struct {
+}
+
+func (c *BadLimitController) Index(cr *ControllerRequest) (string, error) {
+	return "[]", nil
+}
+
+func (c *BadLimitController) RateLimits() map[string]RateLimit {
+	return map[string]RateLimit{"Index": {Requests: 1}, "GetNothing": {Requests: 1, Period: time.Second}}
+}
+
+func serveLimited(router *ControllerRoutingHandler, url, user, remoteAddr string) *httptest.ResponseRecorder {
+	r := newHttpRequest("GET", url, nil)
+	r.Header.Set("X-User", user)
+	r.RemoteAddr = remoteAddr
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, r)
+	return rw
+}
+
+func getLimitedRouter() *ControllerRoutingHandler {
+	router := getMockRouter()
+	router.RateLimits = NewMemoryRateLimitStore()
+	router.RegisterController("limited", &LimitedController{})
+	return router
+}
+
+func TestRateLimitPerUser(t *testing.T) {
+	router := getLimitedRouter()
+	serveLimited(router, "/limited", `{"UserID":1}`, "10.0.0.1:1000")
+	serveLimited(router, "/limited/1", `{"UserID":1}`, "10.0.0.1:1000")
+	last := serveLimited(router, "/limited", `{"UserID":1}`, "10.0.0.1:1000")
+	if last.Code != 200 || last.Header().Get("RateLimit-Limit") != "3" || last.Header().Get("RateLimit-Remaining") != "0" || last.Header().Get("RateLimit-Reset") != "60" {
+		t.Fatal("expected controller quota to be shared by Index and Get", last.Code, last.Header())
+	}
+	limited := serveLimited(router, "/limited/1", `{"UserID":1}`, "10.0.0.1:1000")
+	if limited.Code != 429 || limited.Header().Get("Retry-After") != "20" || getErrorMessage(limited) != "Rate limit exceeded" {
+		t.Fatal("expected 429 once the quota is used up", limited.Code, limited.Header())
+	}
+	if other := serveLimited(router, "/limited", `{"UserID":2}`, "10.0.0.1:1000"); other.Code != 200 {
+		t.Fatal("expected other users to have their own quota", other.Code)
+	}
+}
+
+func TestRateLimitAnonymousUsersByIP(t *testing.T) {
+	router := getLimitedRouter()
+	for i := 0; i < 3; i++ {
+		serveLimited(router, "/limited", "", "10.0.0.1:1000")
+	}
+	if rw := serveLimited(router, "/limited", "", "10.0.0.1:1000"); rw.Code != 429 {
+		t.Fatal("expected anonymous quota to be used up", rw.Code)
+	}
+	if rw := serveLimited(router, "/limited", "", "10.0.0.2:1000"); rw.Code != 200 {
+		t.Fatal("expected other anonymous clients to have their own quota", rw.Code)
+	}
+}
+
+func TestRateLimitByIP(t *testing.T) {
+	router := getLimitedRouter()
+	serveLimited(router, "/limited/1/expensive", `{"UserID":1}`, "10.0.0.1:1000")
+	if rw := serveLimited(router, "/limited/1/expensive", `{"UserID":2}`, "10.0.0.1:2000"); rw.Code != 429 {
+		t.Fatal("expected method limit keyed by IP", rw.Code)
+	}
+	if rw := serveLimited(router, "/limited/1/expensive", `{"UserID":1}`, "10.0.0.2:1000"); rw.Code != 200 {
+		t.Fatal("expected other IPs to have their own quota", rw.Code)
+	}
+}
+
+func TestRateLimitDisabledWithoutStore(t *testing.T) {
+	router := getLimitedRouter()
+	router.RateLimits = nil
+	serveLimited(router, "/limited/1/expensive", "", "10.0.0.1:1000")
+	if rw := serveLimited(router, "/limited/1/expensive", "", "10.0.0.1:1000"); rw.Code != 200 || rw.Header().Get("RateLimit-Limit") != "" {
+		t.Fatal("expected no rate limiting without a store", rw.Code)
+	}
+}
+
+func TestRegisterControllerRateLimitErrors(t *testing.T) {
+	err := NewControllerRoutingHandler().RegisterController("bad", &BadLimitController{})
+	expectedErr := `Method "Index" error: Rate limit must allow at least one request per period
+Method "GetNothing" error: Rate limit for unknown method
+`
+	if err.Error() != expectedErr {
+		t.Fatal("expected rate limit errors", err)
+	}
+}
+
+func TestMemoryRateLimitStoreRefills(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	limit := RateLimit{Requests: 2, Period: time.Second, Burst: 4}
+	for i := 0; i < 4; i++ {
+		store.Take("a", limit)
+	}
+	if result := store.Take("a", limit); result.Allowed || result.RetryAfter != 500*time.Millisecond || result.Reset != 2*time.Second {
+		t.Fatal("expected empty bucket", result)
+	}
+	now = now.Add(time.Second)
+	if result := store.Take("a", limit); !result.Allowed || result.Remaining != 1 {
+		t.Fatal("expected bucket to refill at Requests per Period", result)
+	}
+}
+
+func TestMemoryRateLimitStoreSweepsFullBuckets(t *testing.T) {
+	now := time.Now()
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	store.Take("a", RateLimit{Requests: 1, Period: time.Second})
+	store.Take("b", RateLimit{Requests: 1, Period: time.Hour})
+	now = now.Add(rateLimitSweepInterval)
+	store.Take("c", RateLimit{Requests: 1, Period: time.Second})
+	if store.Len() != 2 {
+		t.Fatal("expected refilled bucket to be swept", store.Len())
+	}
+}
@@ -1,9 +1,12 @@
 package oneweb
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
 type User struct {
@@ -14,45 +17,80 @@ type User struct {
 }
 
 type ControllerRequest struct {
+	RequestID      string
 	ControllerName string
 	ItemID         string
 	Action         string
 	ActionFilter   string
 	User           *User
 	Headers        map[string]string
+	LastModified   time.Time // set by Get and Index methods to send Last-Modified and honor If-Modified-Since
+	CacheControl   string    // set by Get and Index methods to send a Cache-Control header
+	LastEventID    string    // ID of the last Server-Sent Event a reconnecting client received
+	ctx            context.Context
+	pooledUser     *User // owned by controllerRequestPool, unlike User which a controller may replace
 }
 
-func newControllerRequest(r *http.Request) *ControllerRequest {
-	headers := make(map[string]string)
+// Context returns the request's context, which carries cancellation from the client and the request's trace span
+func (cr *ControllerRequest) Context() context.Context {
+	if cr.ctx == nil {
+		return context.Background()
+	}
+	return cr.ctx
+}
+
+var controllerRequestPool = sync.Pool{New: func() interface{} {
+	user := &User{}
+	return &ControllerRequest{User: user, Headers: make(map[string]string), pooledUser: user}
+}}
+
+func newControllerRequest(r *http.Request, naming NamingStrategy) *ControllerRequest {
+	cr := &ControllerRequest{User: &User{}, Headers: make(map[string]string, len(r.Header))}
+	parseControllerRequest(cr, r, naming)
+	return cr
+}
+
+func getPooledControllerRequest(r *http.Request, naming NamingStrategy) *ControllerRequest {
+	cr := controllerRequestPool.Get().(*ControllerRequest)
+	parseControllerRequest(cr, r, naming)
+	return cr
+}
+
+func releaseControllerRequest(cr *ControllerRequest) {
+	for key := range cr.Headers {
+		delete(cr.Headers, key)
+	}
+	*cr.pooledUser = User{}
+	*cr = ControllerRequest{User: cr.pooledUser, Headers: cr.Headers, pooledUser: cr.pooledUser}
+	controllerRequestPool.Put(cr)
+}
+
+func parseControllerRequest(cr *ControllerRequest, r *http.Request, naming NamingStrategy) {
 	for key, value := range r.Header {
 		if len(value) != 0 {
-			headers[key] = value[0]
+			cr.Headers[key] = value[0]
 		}
 	}
 
+	cr.ctx = r.Context()
+	cr.RequestID = getRequestID(r.Header.Get(RequestIDHeader))
+	cr.LastEventID = r.Header.Get("Last-Event-Id")
 	urlPath := removeTrailingSlash(r.URL.Path)
 	urlParams := strings.Split(urlPath, "/")
-	controllerName := strings.Title(strings.ToLower(urlParams[1]))
-
-	var action string
-	var controllerFilter string
-	var actionFilter string
+	cr.ControllerName = naming.Normalize(urlParams[1])
 	if len(urlParams) >= 3 {
-		controllerFilter = urlParams[2]
+		cr.ItemID = urlParams[2]
 	}
 	if len(urlParams) >= 4 {
-		action = urlParams[3]
-		action = strings.Title(strings.ToLower(action))
+		cr.Action = naming.Normalize(urlParams[3])
 	}
 	if len(urlParams) >= 5 {
-		actionFilter = urlParams[4]
+		cr.ActionFilter = urlParams[4]
 	}
 
 	userJSON := r.Header.Get("X-User")
-	user := &User{}
-	json.Unmarshal([]byte(userJSON), user)
-	user.JSON = userJSON
-	return &ControllerRequest{controllerName, controllerFilter, action, actionFilter, user, headers}
+	json.Unmarshal([]byte(userJSON), cr.User)
+	cr.User.JSON = userJSON
 }
 
 func removeTrailingSlash(urlPath string) string {
@@ -0,0 +1,37 @@
+package oneweb
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// RequestIDHeader is read from incoming requests and echoed on every response
+const RequestIDHeader = "X-Request-Id"
+
+const maxRequestIDLength = 128
+
+// getRequestID returns the client supplied ID if it is safe to echo into headers and logs, or a new one
+func getRequestID(incoming string) string {
+	if isValidRequestID(incoming) {
+		return incoming
+	}
+	return newRequestID()
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package oneweb
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetRequestID(t *testing.T) {
+	if id := getRequestID("abc-123_x.y:z"); id != "abc-123_x.y:z" {
+		t.Error("expected incoming request ID to be kept", id)
+	}
+	for _, invalid := range []string{"", "has space", "new\nline", strings.Repeat("a", maxRequestIDLength+1)} {
+		if id := getRequestID(invalid); id == invalid || len(id) != 32 {
+			t.Error("expected new request ID for", invalid, id)
+		}
+	}
+	if newRequestID() == newRequestID() {
+		t.Error("expected unique request IDs")
+	}
+}
+
+func TestRequestIDEchoed(t *testing.T) {
+	logger := &MockLogger{}
+	router := getMockRouter()
+	router.Logger = logger
+	r := newHttpRequest("GET", "/projects/1/error", nil)
+	r.Header.Set("X-Request-ID", "client-id-1")
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, r)
+	if rw.Header().Get(RequestIDHeader) != "client-id-1" || getErrorMessage(rw) != "Internal error calling controller method: failed" ||
+		logger.Entries[0].arg("request_id") != "client-id-1" {
+		t.Fatal("expected request ID in response header, error body and log", rw.Header(), rw.Body.String(), logger.Entries)
+	}
+}
+
+func TestRequestIDGenerated(t *testing.T) {
+	rw := httptest.NewRecorder()
+	getMockRouter().controllerRoutingHandler(rw, newHttpRequest("GET", "/projects/123/Rawmethod", nil))
+	if id := rw.Header().Get(RequestIDHeader); len(id) != 32 {
+		t.Fatal("expected generated request ID on raw method response", rw.Header())
+	}
+}
+
+func TestParseUrlRequestID(t *testing.T) {
+	r := newHttpRequest("GET", "/members", nil)
+	r.Header.Set(RequestIDHeader, "abc")
+	if cr := newControllerRequest(r, LegacyNaming); cr.RequestID != "abc" {
+		t.Fatal("expected RequestID on ControllerRequest", cr.RequestID)
+	}
+}
@@ -7,21 +7,21 @@ import (
 )
 
 func TestParseUrl(t *testing.T) {
-	req := newControllerRequest(newHttpRequest("GET", "/members", nil))
+	req := newControllerRequest(newHttpRequest("GET", "/members", nil), LegacyNaming)
 	if req.ControllerName != "Members" || req.ItemID != "" || req.Action != "" || req.User == nil || req.User.Email != "test@test.com" {
 		t.Fatal("expected controller Members with empty filter and Query.  Actual", req.ControllerName, req.ItemID, req.Action, req.User, req.Headers)
 	}
 }
 
 func TestParseUrlMoreParts(t *testing.T) {
-	req := newControllerRequest(newHttpRequest("GET", "/members/23/doSomething", nil))
+	req := newControllerRequest(newHttpRequest("GET", "/members/23/doSomething", nil), LegacyNaming)
 	if req.ControllerName != "Members" || req.ItemID != "23" || req.Action != "Dosomething" {
 		t.Fatal("expected controller Members filter 23 and Query Dosomething.  Actual", req.ControllerName, req.ItemID, req.Action)
 	}
 }
 
 func TestParseUrlAllParts(t *testing.T) {
-	req := newControllerRequest(newHttpRequest("GET", "/members/23/doSomething/5", nil))
+	req := newControllerRequest(newHttpRequest("GET", "/members/23/doSomething/5", nil), LegacyNaming)
 	if req.ControllerName != "Members" || req.ItemID != "23" || req.Action != "Dosomething" || req.ActionFilter != "5" {
 		t.Fatal("expected controller Members filter 23, Query Dosomething, QueryFilter 5.  Actual", req.ControllerName, req.ItemID, req.Action, req.ActionFilter)
 	}
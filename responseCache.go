@@ -0,0 +1,162 @@
+package oneweb
+
+import (
+	"container/list"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachePolicy opts a Get or Index method into the server-side response cache
+type CachePolicy struct {
+	TTL     time.Duration
+	PerUser bool // include the user ID in the cache key for responses which depend on who is asking
+}
+
+// ResponseCacher is implemented by controllers which cache some of their responses.
+// CachePolicies returns a map of Go method name (e.g. GetReport) to the method's cache policy
+type ResponseCacher interface {
+	CachePolicies() map[string]CachePolicy
+}
+
+type CachedResponse struct {
+	Body         string
+	LastModified time.Time
+	CacheControl string
+}
+
+// ResponseCacheStore holds cached responses grouped by controller so that a successful POST, PUT or
+// DELETE can invalidate everything cached for that controller
+type ResponseCacheStore interface {
+	Get(controller, key string) (CachedResponse, bool)
+	Set(controller, key string, response CachedResponse, ttl time.Duration)
+	Invalidate(controller string)
+}
+
+func getCachePolicies(controller interface{}) map[string]CachePolicy {
+	if cacher, ok := controller.(ResponseCacher); ok {
+		return cacher.CachePolicies()
+	}
+	return nil
+}
+
+// callWithResponseCache calls the method, answering from the response cache when the method has a cache policy
+func (c *ControllerRoutingHandler) callWithResponseCache(rw http.ResponseWriter, r *http.Request, cr *ControllerRequest, key routeKey, method *route, json interface{}) (string, error) {
+	if c.ResponseCache == nil || method.cachePolicy == nil {
+		retVal, err := method.call(r.Method, cr, json)
+		if err == nil {
+			c.invalidateResponseCache(key)
+		}
+		return retVal, err
+	}
+
+	cacheKey := getResponseCacheKey(r, cr, method)
+	if cached, ok := c.ResponseCache.Get(key.controller, cacheKey); ok {
+		rw.Header().Set("X-Cache", "HIT")
+		cr.LastModified, cr.CacheControl = cached.LastModified, cached.CacheControl
+		return cached.Body, nil
+	}
+	rw.Header().Set("X-Cache", "MISS")
+	retVal, err := method.call(r.Method, cr, json)
+	if err == nil {
+		c.ResponseCache.Set(key.controller, cacheKey, CachedResponse{retVal, cr.LastModified, cr.CacheControl}, method.cachePolicy.TTL)
+	}
+	return retVal, err
+}
+
+func (c *ControllerRoutingHandler) invalidateResponseCache(key routeKey) {
+	if c.ResponseCache != nil && key.verb != "Get" && key.verb != "Index" {
+		c.ResponseCache.Invalidate(key.controller)
+	}
+}
+
+func getResponseCacheKey(r *http.Request, cr *ControllerRequest, method *route) string {
+	parts := []string{method.methodName, cr.ItemID, cr.ActionFilter, r.URL.Query().Encode()} // Encode sorts by key
+	if method.cachePolicy.PerUser {
+		parts = append(parts, strconv.Itoa(cr.User.UserID))
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// MemoryResponseCache is a ResponseCacheStore which evicts the least recently used entry once it holds maxEntries
+type MemoryResponseCache struct {
+	maxEntries int
+	lock       sync.Mutex
+	lru        *list.List // front is most recently used
+	entries    map[string]map[string]*list.Element
+	now        func() time.Time
+}
+
+type memoryCacheEntry struct {
+	controller string
+	key        string
+	response   CachedResponse
+	expires    time.Time
+}
+
+func NewMemoryResponseCache(maxEntries int) *MemoryResponseCache {
+	return &MemoryResponseCache{maxEntries: maxEntries, lru: list.New(), entries: make(map[string]map[string]*list.Element), now: time.Now}
+}
+
+func (m *MemoryResponseCache) Get(controller, key string) (CachedResponse, bool) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	element, ok := m.entries[controller][key]
+	if !ok {
+		return CachedResponse{}, false
+	}
+	entry := element.Value.(*memoryCacheEntry)
+	if !entry.expires.IsZero() && !m.now().Before(entry.expires) {
+		m.remove(element)
+		return CachedResponse{}, false
+	}
+	m.lru.MoveToFront(element)
+	return entry.response, true
+}
+
+// Set stores the response.  A zero ttl keeps the entry until it is evicted or invalidated
+func (m *MemoryResponseCache) Set(controller, key string, response CachedResponse, ttl time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	entry := &memoryCacheEntry{controller: controller, key: key, response: response}
+	if ttl > 0 {
+		entry.expires = m.now().Add(ttl)
+	}
+	if element, ok := m.entries[controller][key]; ok {
+		element.Value = entry
+		m.lru.MoveToFront(element)
+		return
+	}
+	if m.entries[controller] == nil {
+		m.entries[controller] = make(map[string]*list.Element)
+	}
+	m.entries[controller][key] = m.lru.PushFront(entry)
+	for m.maxEntries > 0 && m.lru.Len() > m.maxEntries {
+		m.remove(m.lru.Back())
+	}
+}
+
+func (m *MemoryResponseCache) Invalidate(controller string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for _, element := range m.entries[controller] {
+		m.lru.Remove(element)
+	}
+	delete(m.entries, controller)
+}
+
+func (m *MemoryResponseCache) Len() int {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.lru.Len()
+}
+
+func (m *MemoryResponseCache) remove(element *list.Element) {
+	entry := m.lru.Remove(element).(*memoryCacheEntry)
+	delete(m.entries[entry.controller], entry.key)
+	if len(m.entries[entry.controller]) == 0 {
+		delete(m.entries, entry.controller)
+	}
+}
@@ -0,0 +1,151 @@
+package oneweb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type CountingController struct {
+	calls int
+}
+
+func (c *CountingController) Index(cr *ControllerRequest) (string, error) {
+	c.calls++
+	return fmt.Sprintf(`{"calls":%d}`, c.calls), nil
+}
+
+func (c *CountingController) GetReport(cr *ControllerRequest) (string, error) {
+	c.calls++
+	return fmt.Sprintf(`{"calls":%d,"user":%d}`, c.calls, cr.User.UserID), nil
+}
+
+func (c *CountingController) Put(cr *ControllerRequest, data *SimpleData) (string, error) {
+	return "updated", nil
+}
+
+func (c *CountingController) CachePolicies() map[string]CachePolicy {
+	return map[string]CachePolicy{"Index": {TTL: time.Minute}, "GetReport": {PerUser: true}}
+}
+
+type BadCacheController struct {
+}
+
+func (c *BadCacheController) Put(cr *ControllerRequest, data *SimpleData) (string, error) {
+	return "", nil
+}
+
+func (c *BadCacheController) CachePolicies() map[string]CachePolicy {
+	return map[string]CachePolicy{"Put": {}, "GetNothing": {}}
+}
+
+func getCountingRouter() (*ControllerRoutingHandler, *CountingController) {
+	router := getMockRouter()
+	router.ResponseCache = NewMemoryResponseCache(10)
+	controller := &CountingController{}
+	router.RegisterController("counts", controller)
+	return router, controller
+}
+
+func serveCounts(router *ControllerRoutingHandler, method, url, user string) *httptest.ResponseRecorder {
+	r := newHttpRequest(method, url, ioutil.NopCloser(bytes.NewBufferString(`{}`)))
+	if user != "" {
+		r.Header.Set("X-User", user)
+	}
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, r)
+	return rw
+}
+
+func TestResponseCacheHit(t *testing.T) {
+	router, controller := getCountingRouter()
+	first := serveCounts(router, "GET", "/counts?b=2&a=1", "")
+	second := serveCounts(router, "GET", "/counts?a=1&b=2", "")
+	third := serveCounts(router, "GET", "/counts?a=2", "")
+	if controller.calls != 2 || first.Header().Get("X-Cache") != "MISS" || second.Header().Get("X-Cache") != "HIT" ||
+		second.Body.String() != `{"calls":1}` || third.Body.String() != `{"calls":2}` {
+		t.Fatal("expected second request to be served from the cache", controller.calls, second.Body.String(), third.Body.String())
+	}
+}
+
+func TestResponseCachePerUser(t *testing.T) {
+	router, controller := getCountingRouter()
+	serveCounts(router, "GET", "/counts/1/report", `{"UserID":1}`)
+	cached := serveCounts(router, "GET", "/counts/1/report", `{"UserID":1}`)
+	other := serveCounts(router, "GET", "/counts/1/report", `{"UserID":2}`)
+	if controller.calls != 2 || cached.Body.String() != `{"calls":1,"user":1}` || other.Body.String() != `{"calls":2,"user":2}` {
+		t.Fatal("expected responses to be cached per user", controller.calls, cached.Body.String(), other.Body.String())
+	}
+}
+
+func TestResponseCacheInvalidatedByPut(t *testing.T) {
+	router, controller := getCountingRouter()
+	serveCounts(router, "GET", "/counts", "")
+	serveCounts(router, "PUT", "/counts/1", "")
+	rw := serveCounts(router, "GET", "/counts", "")
+	if controller.calls != 2 || rw.Header().Get("X-Cache") != "MISS" {
+		t.Fatal("expected PUT to invalidate cached responses", controller.calls)
+	}
+}
+
+func TestResponseCacheDisabledWithoutStore(t *testing.T) {
+	router, controller := getCountingRouter()
+	router.ResponseCache = nil
+	serveCounts(router, "GET", "/counts", "")
+	if rw := serveCounts(router, "GET", "/counts", ""); controller.calls != 2 || rw.Header().Get("X-Cache") != "" {
+		t.Fatal("expected no caching without a store", controller.calls)
+	}
+}
+
+func TestRegisterControllerCachePolicyErrors(t *testing.T) {
+	err := NewControllerRoutingHandler().RegisterController("bad", &BadCacheController{})
+	expectedErr := `Method "Put" error: Only Get and Index methods returning (string, error) can be cached
+Method "GetNothing" error: Cache policy for unknown method
+`
+	if err.Error() != expectedErr {
+		t.Fatal("expected cache policy errors", err)
+	}
+}
+
+func TestMemoryResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewMemoryResponseCache(2)
+	cache.Set("a", "1", CachedResponse{Body: "1"}, 0)
+	cache.Set("a", "2", CachedResponse{Body: "2"}, 0)
+	cache.Get("a", "1")
+	cache.Set("b", "3", CachedResponse{Body: "3"}, 0)
+	if _, ok := cache.Get("a", "2"); ok || cache.Len() != 2 {
+		t.Fatal("expected least recently used entry to be evicted")
+	}
+	if response, ok := cache.Get("a", "1"); !ok || response.Body != "1" {
+		t.Fatal("expected recently used entry to remain")
+	}
+}
+
+func TestMemoryResponseCacheTTL(t *testing.T) {
+	now := time.Now()
+	cache := NewMemoryResponseCache(0)
+	cache.now = func() time.Time { return now }
+	cache.Set("a", "1", CachedResponse{Body: "1"}, time.Second)
+	cache.Set("a", "1", CachedResponse{Body: "updated"}, time.Second)
+	if response, ok := cache.Get("a", "1"); !ok || response.Body != "updated" {
+		t.Fatal("expected unexpired entry", response)
+	}
+	now = now.Add(time.Second)
+	if _, ok := cache.Get("a", "1"); ok || cache.Len() != 0 {
+		t.Fatal("expected expired entry to be removed")
+	}
+}
+
+func TestMemoryResponseCacheInvalidate(t *testing.T) {
+	cache := NewMemoryResponseCache(0)
+	cache.Set("a", "1", CachedResponse{}, 0)
+	cache.Set("a", "2", CachedResponse{}, 0)
+	cache.Set("b", "1", CachedResponse{}, 0)
+	cache.Invalidate("a")
+	if _, ok := cache.Get("b", "1"); !ok || cache.Len() != 1 {
+		t.Fatal("expected only controller a entries to be invalidated")
+	}
+}
@@ -0,0 +1,149 @@
+package oneweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+type routeKey struct {
+	controller string
+	verb       string
+	action     string
+}
+
+// route is a controller method compiled at registration time.  Methods whose signatures are known
+// up front are called through typed functions so requests don't pay for reflect.Value.Call
+type route struct {
+	reflect.Value
+	methodName    string
+	httpVerb      string
+	urlAction     string // action as it appears in URLs, before normalization
+	raw           func(*ControllerRequest, http.ResponseWriter, *http.Request)
+	stream        func(*ControllerRequest, *ResponseStream) error
+	events        func(*ControllerRequest, chan<- Event) error
+	socket        func(*ControllerRequest, *WebSocket) error
+	invoke        func(*ControllerRequest) (string, error)
+	bodyType      reflect.Type
+	bodyIsPointer bool
+	decodeBody    func(io.Reader) (interface{}, error)
+	invokeBody    func(*ControllerRequest, interface{}) (string, error)
+	cachePolicy   *CachePolicy
+	rateLimit     *RateLimit
+	rateLimitName string // method name, or "*" for the controller's shared quota
+}
+
+func compileRoute(method reflect.Value, methodName, httpVerb string) *route {
+	rt := &route{Value: method, methodName: methodName, httpVerb: httpVerb}
+	methodType := method.Type()
+	switch {
+	case isRawMethod(methodType):
+		rt.raw = method.Interface().(func(*ControllerRequest, http.ResponseWriter, *http.Request))
+	case isStreamMethod(methodType):
+		rt.stream = method.Interface().(func(*ControllerRequest, *ResponseStream) error)
+	case isEventMethod(methodType):
+		rt.events = method.Interface().(func(*ControllerRequest, chan<- Event) error)
+	case isWebSocketMethod(methodType):
+		rt.socket = method.Interface().(func(*ControllerRequest, *WebSocket) error)
+	case methodType.NumIn() == 1:
+		rt.invoke, _ = method.Interface().(func(*ControllerRequest) (string, error)) // nil for named string return types
+	case methodType.NumIn() == 2:
+		rt.bodyType = methodType.In(1)
+		rt.bodyIsPointer = isPointer(rt.bodyType)
+		rt.decodeBody = compileBodyDecoder(rt.bodyType)
+		rt.invokeBody = compileBodyInvoker(method)
+	}
+	return rt
+}
+
+var bodyBufferPool = sync.Pool{New: func() interface{} {
+	return &bytes.Buffer{}
+}}
+
+// maxPooledBodyBuffer keeps buffers grown by unusually large bodies out of the pool
+const maxPooledBodyBuffer = 64 * 1024
+
+// compileBodyDecoder unmarshals request bodies into new values of bodyType, reading them into pooled buffers
+func compileBodyDecoder(bodyType reflect.Type) func(io.Reader) (interface{}, error) {
+	pointer := isPointer(bodyType)
+	valueType := bodyType
+	if pointer {
+		valueType = bodyType.Elem() // pointer of pointer doesn't work
+	}
+	return func(body io.Reader) (interface{}, error) {
+		data := reflect.New(valueType)
+		buf := bodyBufferPool.Get().(*bytes.Buffer)
+		defer func() {
+			if buf.Cap() <= maxPooledBodyBuffer {
+				bodyBufferPool.Put(buf)
+			}
+		}()
+		buf.Reset()
+		if _, err := buf.ReadFrom(body); err != nil {
+			return data.Interface(), err
+		}
+		err := json.Unmarshal(buf.Bytes(), data.Interface()) // copies what it keeps, so buf can be reused
+		if pointer {
+			return data.Interface(), err
+		}
+		return data.Elem().Interface(), err
+	}
+}
+
+// compileBodyInvoker calls a Put or Post method.  The body type is only known at registration so the call
+// goes through reflection, but with a fixed size argument list instead of one built for each request
+func compileBodyInvoker(method reflect.Value) func(*ControllerRequest, interface{}) (string, error) {
+	return func(cr *ControllerRequest, json interface{}) (string, error) {
+		args := [2]reflect.Value{reflect.ValueOf(cr), reflect.ValueOf(json)}
+		ret := method.Call(args[:])
+		if err := ret[1].Interface(); err != nil {
+			return ret[0].String(), err.(error)
+		}
+		return ret[0].String(), nil
+	}
+}
+
+// isStreaming reports whether the method writes its own response over time rather than returning it
+func (rt *route) isStreaming() bool {
+	return rt.stream != nil || rt.events != nil || rt.socket != nil
+}
+
+func (rt *route) call(httpVerb string, cr *ControllerRequest, json interface{}) (string, error) {
+	if rt.invoke != nil {
+		return rt.invoke(cr)
+	}
+	if rt.invokeBody != nil && (httpVerb == "PUT" || httpVerb == "POST") {
+		return rt.invokeBody(cr, json)
+	}
+	return callControllerMethod(&rt.Value, getRequestArguments(httpVerb, cr, json))
+}
+
+func (c *ControllerRoutingHandler) getRoute(key routeKey) *route {
+	return c.loadTable().routes[key]
+}
+
+func getRouteKey(httpVerb string, cr *ControllerRequest) routeKey {
+	verb := getVerbPrefix(httpVerb)
+	if verb == "Get" && cr.ItemID == "" && cr.Action == "" && cr.ActionFilter == "" {
+		return routeKey{cr.ControllerName, "Index", ""}
+	}
+	return routeKey{cr.ControllerName, verb, cr.Action}
+}
+
+func getVerbPrefix(httpVerb string) string {
+	switch httpVerb { // avoid allocating for the common verbs
+	case "GET":
+		return "Get"
+	case "PUT":
+		return "Put"
+	case "POST":
+		return "Post"
+	case "DELETE":
+		return "Delete"
+	}
+	return strings.Title(strings.ToLower(httpVerb))
+}
@@ -0,0 +1,146 @@
+package oneweb
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// NonRouteMarker is implemented by controllers with exported methods which aren't routes, such as helpers used
+// by other packages.  The router and fuzz tester skip the methods named by NonRouteMethods
+type NonRouteMarker interface {
+	NonRouteMethods() []string
+}
+
+// discoveredMethod is an exported controller method as the router sees it.  route is nil for methods the router
+// rejects.  errMsg holds every problem found with the method, including options which couldn't be applied
+type discoveredMethod struct {
+	methodName string
+	key        routeKey
+	route      *route
+	errMsg     string
+}
+
+// discoverRoutes decides which controller methods are routes.  It is the one model shared by the router and the fuzz
+// tester so that fuzz reports cover exactly the routes the router serves.  optionErrMsg reports options for unknown methods
+func discoverRoutes(controller interface{}, controllerName string, naming NamingStrategy) ([]discoveredMethod, string) {
+	overrides := getRouteOverrides(controller)
+	cachePolicies := getCachePolicies(controller)
+	rateLimits := getRateLimits(controller)
+	nonRoutes := getNonRouteMethods(controller)
+	controllerKey := getControllerKey(controllerName, naming)
+	controllerValue := reflect.ValueOf(controller)
+	controllerType := controllerValue.Type()
+	numMethod := controllerValue.NumMethod()
+	routeOwners := make(map[routeKey]string)
+	var methods []discoveredMethod
+	for i := 0; i < numMethod; i++ {
+		methodName := controllerType.Method(i).Name
+		if strings.ToLower(methodName[:1]) == methodName[:1] { // private method (lowercase first letter), so skip
+			continue
+		}
+		if isControllerOptionMethod(controller, methodName) || nonRoutes[methodName] {
+			continue
+		}
+		discovered := discoveredMethod{methodName: methodName}
+		method := controllerValue.Method(i)
+		httpVerb, action, err := validateMethod(method, methodName)
+		if err != nil {
+			discovered.errMsg = err.Error() + "\n"
+			methods = append(methods, discovered)
+			continue
+		}
+
+		urlAction, ok := overrides[methodName]
+		if !ok {
+			urlAction = naming.URLName(action)
+		}
+		discovered.key = routeKey{controllerKey, httpVerb, naming.Normalize(urlAction)}
+		if owner, ok := routeOwners[discovered.key]; ok {
+			discovered.errMsg = fmt.Sprintf("Method \"%s\" error: URL path collides with method \"%s\"\n", methodName, owner)
+			methods = append(methods, discovered)
+			continue
+		}
+		routeOwners[discovered.key] = methodName
+		rt := compileRoute(method, methodName, httpVerb)
+		rt.urlAction = urlAction
+		if policy, ok := cachePolicies[methodName]; ok {
+			if rt.raw != nil || rt.isStreaming() || (httpVerb != "Get" && httpVerb != "Index") {
+				discovered.errMsg += fmt.Sprintf("Method \"%s\" error: Only Get and Index methods returning (string, error) can be cached\n", methodName)
+			} else {
+				rt.cachePolicy = &policy
+			}
+		}
+		if err := rt.setRateLimit(rateLimits, methodName); err != nil {
+			discovered.errMsg += fmt.Sprintf("Method \"%s\" error: %s\n", methodName, err)
+		}
+		discovered.route = rt
+		methods = append(methods, discovered)
+	}
+
+	var overridden, cached, limited, typed, helpers []string
+	for methodName := range overrides {
+		overridden = append(overridden, methodName)
+	}
+	for methodName := range cachePolicies {
+		cached = append(cached, methodName)
+	}
+	for methodName := range rateLimits {
+		if methodName != controllerRateLimit {
+			limited = append(limited, methodName)
+		}
+	}
+	for methodName := range getResponseTypes(controller) {
+		typed = append(typed, methodName)
+	}
+	for methodName := range nonRoutes {
+		helpers = append(helpers, methodName)
+	}
+	optionErrMsg := getUnknownMethodErrors(controllerType, overridden, "Route override")
+	optionErrMsg += getUnknownMethodErrors(controllerType, cached, "Cache policy")
+	optionErrMsg += getUnknownMethodErrors(controllerType, limited, "Rate limit")
+	optionErrMsg += getUnknownMethodErrors(controllerType, typed, "Response type")
+	optionErrMsg += getUnknownMethodErrors(controllerType, helpers, "Non-route marker")
+	return methods, optionErrMsg
+}
+
+func getUnknownMethodErrors(controllerType reflect.Type, methodNames []string, option string) string {
+	sort.Strings(methodNames)
+	var errMsg string
+	for _, methodName := range methodNames {
+		if _, ok := controllerType.MethodByName(methodName); !ok {
+			errMsg += fmt.Sprintf("Method \"%s\" error: %s for unknown method\n", methodName, option)
+		}
+	}
+	return errMsg
+}
+
+func getRouteOverrides(controller interface{}) map[string]string {
+	if overrider, ok := controller.(RouteOverrider); ok {
+		return overrider.RouteOverrides()
+	}
+	return nil
+}
+
+// isControllerOptionMethod reports whether the method configures the router rather than serving a route
+func isControllerOptionMethod(controller interface{}, methodName string) bool {
+	_, overrider := controller.(RouteOverrider)
+	_, cacher := controller.(ResponseCacher)
+	_, limiter := controller.(RateLimiter)
+	_, typer := controller.(ResponseTyper)
+	_, marker := controller.(NonRouteMarker)
+	return (overrider && methodName == "RouteOverrides") || (cacher && methodName == "CachePolicies") ||
+		(limiter && methodName == "RateLimits") || (typer && methodName == "ResponseTypes") ||
+		(marker && methodName == "NonRouteMethods")
+}
+
+func getNonRouteMethods(controller interface{}) map[string]bool {
+	nonRoutes := make(map[string]bool)
+	if marker, ok := controller.(NonRouteMarker); ok {
+		for _, methodName := range marker.NonRouteMethods() {
+			nonRoutes[methodName] = true
+		}
+	}
+	return nonRoutes
+}
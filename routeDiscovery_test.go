@@ -0,0 +1,109 @@
+package oneweb
+
+import (
+	"testing"
+	"time"
+)
+
+type HelperController struct {
+}
+
+func (c *HelperController) Get(cr *ControllerRequest) (string, error) {
+	return "{}", nil
+}
+
+func (c *HelperController) GetArchive(cr *ControllerRequest) (string, error) {
+	return "{}", nil
+}
+
+func (c *HelperController) GetHelper(cr *ControllerRequest) (string, error) {
+	return "{}", nil
+}
+
+func (c *HelperController) FormatName(name string) string {
+	return name
+}
+
+func (c *HelperController) NonRouteMethods() []string {
+	return []string{"GetHelper", "FormatName", "Missing"}
+}
+
+func TestDiscoverRoutesSkipsNonRoutes(t *testing.T) {
+	methods, optionErr := discoverRoutes(&HelperController{}, "helper", LegacyNaming)
+	if optionErr != "Method \"Missing\" error: Non-route marker for unknown method\n" {
+		t.Fatal("expected unknown non-route marker error", optionErr)
+	}
+	if len(methods) != 2 || methods[0].methodName != "Get" || methods[1].methodName != "GetArchive" {
+		t.Fatal("expected only route methods to be discovered", methods)
+	}
+	for _, method := range methods {
+		if method.route == nil || method.errMsg != "" {
+			t.Error("expected valid route", method.methodName, method.errMsg)
+		}
+	}
+}
+
+func TestRouterAndFuzzTesterAgree(t *testing.T) {
+	router := NewControllerRoutingHandler()
+	err := router.RegisterController("helper", &HelperController{})
+	if err.Error() != "Method \"Missing\" error: Non-route marker for unknown method\n" {
+		t.Fatal("expected unknown non-route marker error", err)
+	}
+	if len(router.loadTable().routes) != 2 {
+		t.Fatal("expected router to skip non-route methods", len(router.loadTable().routes))
+	}
+
+	results := FuzzTestController(&HelperController{})
+	if len(results) != 2 || results[0].MethodName != "Get" || results[1].MethodName != "GetArchive" {
+		t.Fatal("expected fuzz tester to call the same methods as the router", results)
+	}
+	if results[0].HTTPMethod != "GET" || results[0].URL != "/helper/1" {
+		t.Error("expected Get url", results[0].HTTPMethod, results[0].URL)
+	}
+	if results[1].HTTPMethod != "GET" || results[1].URL != "/helper/1/Archive" {
+		t.Error("expected GetArchive url", results[1].HTTPMethod, results[1].URL)
+	}
+}
+
+func TestFuzzResultURLOptions(t *testing.T) {
+	results := FuzzTestControllerWithOptions(&HelperController{}, FuzzOptions{ControllerName: "helpers", Naming: CamelCaseNaming, ItemIDs: []string{"7"}})
+	if len(results) != 2 || results[1].URL != "/helpers/7/archive" {
+		t.Fatal("expected url to use controller name, naming and item id", results)
+	}
+}
+
+func TestFuzzReportsRejectedMethods(t *testing.T) {
+	results := FuzzTestController(&MockController{})
+	for _, result := range results {
+		if result.MethodName == "GetWrongReturnType" {
+			if result.ValidationError == nil || result.ReturnData != nil || result.URL != "" {
+				t.Fatal("expected rejected method to be reported without being called", result)
+			}
+			return
+		}
+	}
+	t.Fatal("expected GetWrongReturnType to be reported")
+}
+
+type BadOptionController struct {
+}
+
+func (c *BadOptionController) Post(cr *ControllerRequest, project *ContractProject) (string, error) {
+	return "{}", nil
+}
+
+func (c *BadOptionController) CachePolicies() map[string]CachePolicy {
+	return map[string]CachePolicy{"Post": {TTL: time.Minute}}
+}
+
+func TestFuzzReportsRoutesWithInvalidOptions(t *testing.T) {
+	results := FuzzTestController(&BadOptionController{})
+	expected := "Method \"Post\" error: Only Get and Index methods returning (string, error) can be cached"
+	if len(results) != 1 || results[0].ValidationError == nil || results[0].ValidationError.Error() != expected || results[0].ReturnData == nil {
+		t.Fatal("expected route to be called and its invalid option reported", results)
+	}
+	coverage := SummarizeFuzzResults(results)
+	if coverage.Calls != 1 || len(coverage.Skipped) != 0 {
+		t.Error("expected route with invalid option to be covered", coverage)
+	}
+}
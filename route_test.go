@@ -0,0 +1,147 @@
+package oneweb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type jsonString string
+
+type NamedReturnController struct {
+}
+
+func (c *NamedReturnController) Get(cr *ControllerRequest) (jsonString, error) {
+	return "called named Get", nil
+}
+
+func TestCompileRoute(t *testing.T) {
+	router := getMockRouter()
+	index := router.getRoute(routeKey{"Projects", "Index", ""})
+	raw := router.getRoute(routeKey{"Projects", "Get", "Rawmethod"})
+	put := router.getRoute(routeKey{"Projects", "Put", "Valid"})
+	if index.invoke == nil || index.raw != nil || index.bodyType != nil {
+		t.Error("expected typed invoker for Index", index)
+	}
+	if raw.raw == nil || raw.invoke != nil {
+		t.Error("expected typed raw method", raw)
+	}
+	if put.invoke != nil || put.invokeBody == nil || put.decodeBody == nil || put.bodyType != reflect.TypeOf([]SimpleData{}) || put.bodyIsPointer {
+		t.Error("expected compiled body decoder and invoker with slice body", put)
+	}
+}
+
+func TestCompileBodyDecoder(t *testing.T) {
+	decode := compileBodyDecoder(reflect.TypeOf(&SimpleData{}))
+	first, err := decode(bytes.NewBufferString(`{"Hello":"first"}`))
+	if err != nil || first.(*SimpleData).Hello != "first" {
+		t.Fatal("expected pointer body", first, err)
+	}
+	second, err := compileBodyDecoder(reflect.TypeOf([]SimpleData{}))(bytes.NewBufferString(`[{"Hello":"second"}]`))
+	if err != nil || second.([]SimpleData)[0].Hello != "second" || first.(*SimpleData).Hello != "first" {
+		t.Fatal("expected slice body without reusing the first body's memory", first, second, err)
+	}
+}
+
+func TestCompileRouteNamedStringReturn(t *testing.T) {
+	rt := compileRoute(reflect.ValueOf(&NamedReturnController{}).MethodByName("Get"), "Get", "Get")
+	retVal, err := rt.call("GET", &ControllerRequest{}, nil)
+	if rt.invoke != nil || retVal != "called named Get" || err != nil {
+		t.Fatal("expected fallback to reflection for named string return", retVal, err)
+	}
+}
+
+func TestGetRouteKey(t *testing.T) {
+	if key := getRouteKey("GET", &ControllerRequest{ControllerName: "Projects"}); key != (routeKey{"Projects", "Index", ""}) {
+		t.Error("expected Index key", key)
+	}
+	if key := getRouteKey("DELETE", &ControllerRequest{ControllerName: "Projects", ItemID: "1", Action: "Stuff"}); key != (routeKey{"Projects", "Delete", "Stuff"}) {
+		t.Error("expected DeleteStuff key", key)
+	}
+	if key := getRouteKey("patch", &ControllerRequest{ControllerName: "Projects", ItemID: "1"}); key != (routeKey{"Projects", "Patch", ""}) {
+		t.Error("expected Patch key", key)
+	}
+}
+
+func TestReuseRequests(t *testing.T) {
+	router := getMockRouter()
+	router.ReuseRequests = true
+	for i := 0; i < 2; i++ {
+		rw := httptest.NewRecorder()
+		router.controllerRoutingHandler(rw, newHttpRequest("GET", "/projects/123/method", nil))
+		if body := rw.Body.String(); body != "called GetMethod" {
+			t.Fatal("expected to be able to call GetMethod method", body)
+		}
+	}
+}
+
+func TestReleaseControllerRequest(t *testing.T) {
+	cr := getPooledControllerRequest(newHttpRequest("GET", "/members/23/doSomething/5", nil), LegacyNaming)
+	headers, user := cr.Headers, cr.User
+	releaseControllerRequest(cr)
+	if cr.ControllerName != "" || cr.ItemID != "" || cr.Action != "" || cr.ActionFilter != "" ||
+		len(headers) != 0 || user.Email != "" || cr.Headers == nil || cr.User == nil {
+		t.Fatal("expected released request to be reset", cr)
+	}
+}
+
+func TestReleaseControllerRequestKeepsAssignedUser(t *testing.T) {
+	cr := getPooledControllerRequest(newHttpRequest("GET", "/members/23", nil), LegacyNaming)
+	pooled := cr.User
+	shared := &User{UserID: 9, Email: "shared@example.com"}
+	cr.User = shared
+	releaseControllerRequest(cr)
+	if shared.UserID != 9 || shared.Email != "shared@example.com" || cr.User != pooled {
+		t.Fatal("expected only the pool's own User to be reset", shared, cr.User)
+	}
+}
+
+func BenchmarkReflectCall(b *testing.B) {
+	method := reflect.ValueOf(&MockController{}).MethodByName("GetMethod")
+	cr := &ControllerRequest{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		callControllerMethod(&method, getRequestArguments("GET", cr, nil))
+	}
+}
+
+func BenchmarkCompiledCall(b *testing.B) {
+	rt := getMockRouter().getRoute(routeKey{"Projects", "Get", "Method"})
+	cr := &ControllerRequest{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		rt.call("GET", cr, nil)
+	}
+}
+
+func BenchmarkHandlerGet(b *testing.B) {
+	benchmarkHandler(b, false, "GET", "/projects/123/method", "")
+}
+
+func BenchmarkHandlerGetReuseRequests(b *testing.B) {
+	benchmarkHandler(b, true, "GET", "/projects/123/method", "")
+}
+
+func BenchmarkHandlerPut(b *testing.B) {
+	benchmarkHandler(b, false, "PUT", "/projects/123", `{ "hello": "there" }`)
+}
+
+func BenchmarkHandlerPutReuseRequests(b *testing.B) {
+	benchmarkHandler(b, true, "PUT", "/projects/123", `{ "hello": "there" }`)
+}
+
+func benchmarkHandler(b *testing.B, reuseRequests bool, method, url, body string) {
+	router := getMockRouter()
+	router.ReuseRequests = reuseRequests
+	r := newHttpRequest(method, url, nil)
+	rw := httptest.NewRecorder()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Body = ioutil.NopCloser(bytes.NewBufferString(body))
+		rw.Body.Reset()
+		router.controllerRoutingHandler(rw, r)
+	}
+}
@@ -0,0 +1,52 @@
+package oneweb
+
+// routingTable is never modified once it has been published to the handler.  Registration changes
+// copy the current table, modify the copy and store it in place of the old one
+type routingTable struct {
+	controllers    map[string]interface{}
+	controllerKeys map[string]string // registered name => normalized controller name used in routeKey
+	routes         map[routeKey]*route
+}
+
+var emptyRoutingTable = newRoutingTable()
+
+func newRoutingTable() *routingTable {
+	return &routingTable{controllers: make(map[string]interface{}), controllerKeys: make(map[string]string), routes: make(map[routeKey]*route)}
+}
+
+func (c *ControllerRoutingHandler) loadTable() *routingTable {
+	if table, ok := c.table.Load().(*routingTable); ok {
+		return table
+	}
+	return emptyRoutingTable
+}
+
+// without returns a copy of the table with the controller and its routes removed
+func (t *routingTable) without(controllerKey string) *routingTable {
+	table := newRoutingTable()
+	for name, controller := range t.controllers {
+		if t.controllerKeys[name] != controllerKey {
+			table.controllers[name] = controller
+			table.controllerKeys[name] = t.controllerKeys[name]
+		}
+	}
+	for key, rt := range t.routes {
+		if key.controller != controllerKey {
+			table.routes[key] = rt
+		}
+	}
+	return table
+}
+
+func (t *routingTable) hasController(controllerKey string) bool {
+	for _, key := range t.controllerKeys {
+		if key == controllerKey {
+			return true
+		}
+	}
+	return false
+}
+
+func getControllerKey(name string, naming NamingStrategy) string {
+	return naming.Normalize(naming.URLName(name))
+}
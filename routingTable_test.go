@@ -0,0 +1,116 @@
+package oneweb
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestRegisterControllerReplacesExisting(t *testing.T) {
+	router := getMockRouter()
+	router.RegisterController("Projects", &NamingController{})
+	controllers := router.Controllers
+	if len(controllers) != 1 || controllers["Projects"] == nil || len(router.loadTable().routes) != 3 {
+		t.Fatal("expected Projects controller to replace projects controller", controllers, router.loadTable().routes)
+	}
+}
+
+func TestUnregisterController(t *testing.T) {
+	router := getMockRouter()
+	router.RegisterController("users", &NamingController{})
+	if !router.UnregisterController("Projects") || router.UnregisterController("projects") {
+		t.Fatal("expected to unregister projects only once")
+	}
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, newHttpRequest("GET", "/projects", nil))
+	if len(router.Controllers) != 1 || len(router.loadTable().routes) != 3 || getErrorMessage(rw) != "Method \"Index\" not found" {
+		t.Fatal("expected only users routes to remain", router.Controllers, rw.Body.String())
+	}
+}
+
+func TestReplaceControllers(t *testing.T) {
+	router := getMockRouter()
+	err := router.ReplaceControllers(map[string]interface{}{"users": &NamingController{}, "accounts": &NamingController{}})
+	if err.Error() != "" || len(router.Controllers) != 2 || len(router.loadTable().routes) != 6 || router.getRoute(routeKey{"Projects", "Index", ""}) != nil {
+		t.Fatal("expected routing table to be replaced", err, router.Controllers)
+	}
+}
+
+func TestControllersIsSnapshot(t *testing.T) {
+	router := getMockRouter()
+	router.Controllers["other"] = &MockController{}
+	if len(router.loadTable().controllers) != 1 {
+		t.Fatal("expected changes to the snapshot not to register controllers")
+	}
+	router.RegisterController("users", &NamingController{})
+	if len(router.Controllers) != 2 || router.Controllers["other"] != nil || router.Controllers["users"] == nil {
+		t.Fatal("expected snapshot to be replaced on registration", router.Controllers)
+	}
+}
+
+func TestRegisteredControllersWhileRegistering(t *testing.T) {
+	router := getMockRouter()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			router.RegisterController("users", &NamingController{})
+			router.UnregisterController("users")
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		if controllers := router.RegisteredControllers(); controllers["projects"] == nil {
+			t.Fatal("expected projects to stay registered", controllers)
+		}
+	}
+	wg.Wait()
+	controllers := router.RegisteredControllers()
+	controllers["other"] = &MockController{}
+	if len(router.RegisteredControllers()) != 1 {
+		t.Fatal("expected changes to the returned map not to register controllers")
+	}
+}
+
+func TestReplaceControllersDuplicateNames(t *testing.T) {
+	router := getMockRouter()
+	err := router.ReplaceControllers(map[string]interface{}{"users": &MockController{}, "Users": &NamingController{}})
+	if err.Error() != "Controller \"users\" error: Same URL as controller \"Users\"\n" || len(router.Controllers) != 1 || router.Controllers["Users"] == nil {
+		t.Fatal("expected duplicate controller name to be reported", err, router.Controllers)
+	}
+}
+
+func TestZeroValueRouter(t *testing.T) {
+	router := &ControllerRoutingHandler{}
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, newHttpRequest("GET", "/projects", nil))
+	if len(router.Controllers) != 0 || rw.Code != 500 {
+		t.Fatal("expected empty routing table")
+	}
+}
+
+func TestRegisterWhileServing(t *testing.T) {
+	router := getMockRouter()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				router.RegisterController("users", &NamingController{})
+				router.UnregisterController("users")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				rw := httptest.NewRecorder()
+				router.controllerRoutingHandler(rw, newHttpRequest("GET", "/projects/123/method", nil))
+				if body := rw.Body.String(); body != "called GetMethod" {
+					t.Error("expected projects to keep serving while users changes", body)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,121 @@
+package oneweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Schema describes the shape of a JSON value using a subset of JSON Schema
+type Schema struct {
+	Type       string             `json:"type,omitempty"` // object, array, string, number, integer, boolean or null.  Any type if empty
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+}
+
+// ResponseTyper is implemented by controllers which declare what their methods return.  ResponseTypes returns a map
+// of Go method name to either a *Schema or a value of the Go type the response must decode into without unknown fields
+type ResponseTyper interface {
+	ResponseTypes() map[string]interface{}
+}
+
+func getResponseTypes(controller interface{}) map[string]interface{} {
+	if typer, ok := controller.(ResponseTyper); ok {
+		return typer.ResponseTypes()
+	}
+	return nil
+}
+
+// ValidateJSON checks that body is a single JSON value matching the schema
+func (s *Schema) ValidateJSON(body []byte) error {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return errors.Wrap(err, "Invalid JSON")
+	}
+	if decoder.More() {
+		return errors.New("Invalid JSON: unexpected data after the top-level value")
+	}
+	return s.validate(value, "$")
+}
+
+func (s *Schema) validate(value interface{}, path string) error {
+	if s == nil {
+		return nil
+	}
+	if s.Type != "" && getJSONType(value, s.Type) != s.Type {
+		return fmt.Errorf("%s: expected %s, got %s", path, s.Type, getJSONType(value, ""))
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				return fmt.Errorf("%s: missing required property \"%s\"", path, name)
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := value[name]; ok {
+				if err := s.Properties[name].validate(property, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// getJSONType names the JSON type of a decoded value.  Whole numbers are reported as integer when integer is expected
+func getJSONType(value interface{}, expected string) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := value.Int64(); err == nil && expected == "integer" {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+// validateResponseType checks body against a *Schema or Go value from ResponseTypes
+func validateResponseType(body string, expected interface{}) error {
+	if schema, ok := expected.(*Schema); ok {
+		return schema.ValidateJSON([]byte(body))
+	}
+	target := reflect.New(reflect.TypeOf(expected))
+	decoder := json.NewDecoder(bytes.NewBufferString(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(target.Interface()); err != nil {
+		return errors.Wrapf(err, "Response doesn't match %s", reflect.TypeOf(expected))
+	}
+	if decoder.More() {
+		return errors.New("Invalid JSON: unexpected data after the top-level value")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package oneweb
+
+import (
+	"testing"
+)
+
+var projectSchema = &Schema{
+	Type:     "object",
+	Required: []string{"id", "tags"},
+	Properties: map[string]*Schema{
+		"id":   {Type: "integer"},
+		"name": {Type: "string"},
+		"tags": {Type: "array", Items: &Schema{Type: "string"}},
+	},
+}
+
+func TestSchemaValidateJSON(t *testing.T) {
+	if err := projectSchema.ValidateJSON([]byte(`{"id":1,"tags":["a"],"extra":null}`)); err != nil {
+		t.Error("expected valid project", err)
+	}
+	for body, expectedErr := range map[string]string{
+		`{"id":1.5,"tags":[]}`:         "$.id: expected integer, got number",
+		`{"id":1}`:                     "$: missing required property \"tags\"",
+		`{"id":1,"tags":["a",2]}`:      "$.tags[1]: expected string, got number",
+		`[]`:                           "$: expected object, got array",
+		`{"id":1,"tags":[]} {}`:        "Invalid JSON: unexpected data after the top-level value",
+		`{"id":1,"tags":[],"name":""`:  "Invalid JSON: unexpected EOF",
+		`{"id":1,"tags":[],"name":{}}`: "$.name: expected string, got object",
+	} {
+		if err := projectSchema.ValidateJSON([]byte(body)); err == nil || err.Error() != expectedErr {
+			t.Error("unexpected validation result", body, err)
+		}
+	}
+}
+
+func TestValidateResponseTypeGoType(t *testing.T) {
+	if err := validateResponseType(`[{"Hello":"world"}]`, []SimpleData{}); err != nil {
+		t.Error("expected response to decode", err)
+	}
+	if err := validateResponseType(`{"Goodbye":"world"}`, SimpleData{}); err == nil || err.Error() != "Response doesn't match oneweb.SimpleData: json: unknown field \"Goodbye\"" {
+		t.Error("expected unknown field error", err)
+	}
+}
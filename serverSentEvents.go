@@ -0,0 +1,119 @@
+package oneweb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultSSEHeartbeat = 15 * time.Second
+
+// Event is sent to the client by Get and Index methods with the signature
+// (cr *ControllerRequest, events chan<- Event) error, which the router serves as Server-Sent Events.
+// Methods should send events until they are done or cr.Context() is cancelled, and must not close events.
+// Clients reconnecting after a dropped connection send the last ID they saw in cr.LastEventID
+type Event struct {
+	ID    string
+	Event string // event type, "message" if empty
+	Data  string
+	Retry time.Duration // reconnection delay for the client to use
+}
+
+func (e Event) String() string {
+	b := &strings.Builder{}
+	if e.ID != "" {
+		fmt.Fprintf(b, "id: %s\n", stripNewlines(e.ID))
+	}
+	if e.Event != "" {
+		fmt.Fprintf(b, "event: %s\n", stripNewlines(e.Event))
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(b, "retry: %d\n", e.Retry/time.Millisecond)
+	}
+	for _, line := range strings.Split(dataLineBreaks.Replace(e.Data), "\n") {
+		fmt.Fprintf(b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// dataLineBreaks turns each of the line terminators clients accept, \r\n, \n and a lone \r, into \n
+var dataLineBreaks = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+func stripNewlines(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// callEventMethod runs the method in its own goroutine and writes its events to the client along with
+// heartbeat comments that keep proxies from closing an idle connection.  It doesn't return until the
+// method has returned, so cr is never used after the request completes
+func (c *ControllerRoutingHandler) callEventMethod(cr *ControllerRequest, method *route, rw http.ResponseWriter) error {
+	rw.Header().Add("Access-Control-Allow-Origin", "*")
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+	flush(rw)
+
+	ctx, cancel := context.WithCancel(cr.Context())
+	defer cancel()
+	cr.ctx = ctx
+	events := make(chan Event)
+	done := make(chan error, 1)
+	go func() {
+		done <- method.events(cr, events)
+	}()
+
+	heartbeat := time.NewTicker(c.sseHeartbeat())
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event := <-events:
+			if _, err := rw.Write([]byte(event.String())); err != nil {
+				cancel()
+				return drainEvents(events, done, err)
+			}
+			flush(rw)
+		case <-heartbeat.C:
+			if _, err := rw.Write([]byte(": heartbeat\n\n")); err != nil {
+				cancel()
+				return drainEvents(events, done, err)
+			}
+			flush(rw)
+		case <-ctx.Done(): // client disconnected
+			return drainEvents(events, done, nil)
+		case err := <-done:
+			if err != nil {
+				return errors.Wrap(err, "Internal error calling controller method")
+			}
+			return nil
+		}
+	}
+}
+
+// drainEvents discards events until the method notices its context has been cancelled and returns
+func drainEvents(events <-chan Event, done <-chan error, err error) error {
+	for {
+		select {
+		case <-events:
+		case <-done:
+			return err
+		}
+	}
+}
+
+func (c *ControllerRoutingHandler) sseHeartbeat() time.Duration {
+	if c.SSEHeartbeat <= 0 {
+		return defaultSSEHeartbeat
+	}
+	return c.SSEHeartbeat
+}
+
+func flush(rw http.ResponseWriter) {
+	if flusher, ok := rw.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
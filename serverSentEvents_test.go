@@ -0,0 +1,122 @@
+package oneweb
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type EventController struct {
+}
+
+func (c *EventController) GetTicks(cr *ControllerRequest, events chan<- Event) error {
+	start, _ := strconv.Atoi(cr.LastEventID)
+	for i := start + 1; i <= start+2; i++ {
+		events <- Event{ID: strconv.Itoa(i), Event: "tick", Data: "tick " + strconv.Itoa(i)}
+	}
+	return nil
+}
+
+func (c *EventController) GetSlow(cr *ControllerRequest, events chan<- Event) error {
+	time.Sleep(20 * time.Millisecond)
+	return nil
+}
+
+func (c *EventController) GetForever(cr *ControllerRequest, events chan<- Event) error {
+	for {
+		select {
+		case events <- Event{Data: "again"}:
+		case <-cr.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (c *EventController) GetBroken(cr *ControllerRequest, events chan<- Event) error {
+	return errors.New("feed went away")
+}
+
+func (c *EventController) PutEvents(cr *ControllerRequest, events chan<- Event) error {
+	return nil
+}
+
+func getEventRouter() *ControllerRoutingHandler {
+	router := getMockRouter()
+	router.RegisterController("events", &EventController{})
+	return router
+}
+
+func TestEventString(t *testing.T) {
+	event := Event{ID: "1\n2", Event: "update", Data: "line 1\r\nline 2", Retry: 2 * time.Second}
+	if event.String() != "id: 12\nevent: update\nretry: 2000\ndata: line 1\ndata: line 2\n\n" {
+		t.Fatal("unexpected event format", event.String())
+	}
+}
+
+func TestEventStringLoneCarriageReturn(t *testing.T) {
+	event := Event{Data: "a\rid: 99\r\nb\n\rc"}
+	if event.String() != "data: a\ndata: id: 99\ndata: b\ndata: \ndata: c\n\n" {
+		t.Fatal("expected a lone carriage return to start a new data line", event.String())
+	}
+}
+
+func TestServerSentEvents(t *testing.T) {
+	r := newHttpRequest("GET", "/events/1/ticks", nil)
+	r.Header.Set("Last-Event-ID", "4")
+	rw := httptest.NewRecorder()
+	getEventRouter().controllerRoutingHandler(rw, r)
+	expected := "id: 5\nevent: tick\ndata: tick 5\n\nid: 6\nevent: tick\ndata: tick 6\n\n"
+	if rw.Code != 200 || rw.Body.String() != expected || rw.Header().Get("Content-Type") != "text/event-stream" || !rw.Flushed {
+		t.Fatal("expected events resuming after Last-Event-ID", rw.Code, rw.Body.String())
+	}
+}
+
+func TestServerSentEventsHeartbeat(t *testing.T) {
+	router := getEventRouter()
+	router.SSEHeartbeat = time.Millisecond
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, newHttpRequest("GET", "/events/1/slow", nil))
+	if !strings.HasPrefix(rw.Body.String(), ": heartbeat\n\n") {
+		t.Fatal("expected heartbeat comments while the method is idle", rw.Body.String())
+	}
+}
+
+func TestServerSentEventsClientDisconnects(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rw := httptest.NewRecorder()
+	getEventRouter().controllerRoutingHandler(rw, newHttpRequest("GET", "/events/1/forever", nil).WithContext(ctx))
+	if rw.Code != 200 {
+		t.Fatal("expected method to stop once the client has gone away", rw.Code)
+	}
+}
+
+func TestServerSentEventsError(t *testing.T) {
+	logger := &MockLogger{}
+	router := getEventRouter()
+	router.Logger = logger
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, newHttpRequest("GET", "/events/1/broken", nil))
+	if rw.Code != 200 || rw.Body.String() != "" || logger.Entries[0].arg("error") != "Internal error calling controller method: feed went away" {
+		t.Fatal("expected error to be logged after the stream started", rw.Code, rw.Body.String(), logger.Entries)
+	}
+}
+
+func TestValidateEventMethod(t *testing.T) {
+	err := NewControllerRoutingHandler().RegisterController("events", &EventController{})
+	if err.Error() != "Method \"PutEvents\" error: Only Get and Index methods can stream responses\n" {
+		t.Fatal("expected only PutEvents to be rejected", err)
+	}
+}
+
+func TestFuzzTestControllerEventMethod(t *testing.T) {
+	result := fuzzTestControllerMethod(&EventController{}, "GetForever")
+	if result.ValidationError != nil || !strings.HasPrefix(result.ReturnData[0].(string), "data: again\n\n") || result.ReturnData[1] != nil {
+		t.Fatal("expected events until the fuzz timeout", result)
+	}
+}
@@ -0,0 +1,180 @@
+package oneweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// UpdateSnapshotsEnv rewrites snapshots instead of comparing when set to 1, e.g. UPDATE_SNAPSHOTS=1 go test ./...
+const UpdateSnapshotsEnv = "UPDATE_SNAPSHOTS"
+
+// SnapshotOptions configures SnapshotTestController
+type SnapshotOptions struct {
+	Dir    string      // testdata/snapshots if not set
+	Update bool        // write the current output as the new snapshots
+	Inputs FuzzOptions // fixed inputs each method is called with
+}
+
+type snapshotEntry struct {
+	Input    snapshotInput   `json:"input"`
+	Response json.RawMessage `json:"response"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type snapshotInput struct {
+	UserID       int               `json:"userId"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	ItemID       string            `json:"itemId,omitempty"`
+	ActionFilter string            `json:"actionFilter,omitempty"`
+}
+
+// SnapshotTestController calls each valid method with the fixed inputs and compares the output with the
+// golden file Dir/name/MethodName.json, reporting differences as a line diff of the indented JSON
+func SnapshotTestController(t TestRunner, name string, controller interface{}, options SnapshotOptions) {
+	dir := options.Dir
+	if dir == "" {
+		dir = filepath.Join("testdata", "snapshots")
+	}
+	dir = filepath.Join(dir, name)
+	update := options.Update || os.Getenv(UpdateSnapshotsEnv) == "1"
+
+	snapshots := make(map[string][]snapshotEntry)
+	var methodNames []string
+	for _, result := range FuzzTestControllerWithOptions(controller, options.Inputs) {
+		if result.ValidationError != nil {
+			continue
+		}
+		if _, ok := snapshots[result.MethodName]; !ok {
+			methodNames = append(methodNames, result.MethodName)
+		}
+		snapshots[result.MethodName] = append(snapshots[result.MethodName], getSnapshotEntry(result))
+	}
+
+	for _, methodName := range methodNames {
+		actual, _ := json.MarshalIndent(snapshots[methodName], "", "  ")
+		actual = append(actual, '\n')
+		filename := filepath.Join(dir, methodName+".json")
+		if update {
+			if err := writeSnapshot(filename, actual); err != nil {
+				t.Error(err)
+			}
+			continue
+		}
+		expected, err := ioutil.ReadFile(filename)
+		if err != nil {
+			t.Error(fmt.Sprintf("Missing snapshot %s.  Run with %s=1 to create it", filename, UpdateSnapshotsEnv))
+		} else if !bytes.Equal(expected, actual) {
+			t.Error(fmt.Sprintf("Snapshot %s doesn't match:\n%s", filename, diffLines(string(expected), string(actual))))
+		}
+	}
+}
+
+func getSnapshotEntry(result MethodTestResult) snapshotEntry {
+	entry := snapshotEntry{Input: snapshotInput{Headers: result.Input.Headers, ItemID: result.Input.ItemID, ActionFilter: result.Input.ActionFilter}}
+	if result.Input.User != nil {
+		entry.Input.UserID = result.Input.User.UserID
+	}
+	if len(result.ReturnData) > 0 {
+		body := fmt.Sprint(result.ReturnData[0])
+		if json.Valid([]byte(body)) {
+			entry.Response = json.RawMessage(body)
+		} else {
+			entry.Response, _ = json.Marshal(body)
+		}
+	}
+	if len(result.ReturnData) > 1 {
+		if err, ok := result.ReturnData[len(result.ReturnData)-1].(error); ok {
+			entry.Error = err.Error()
+		}
+	}
+	return entry
+}
+
+func writeSnapshot(filename string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0644)
+}
+
+// maxDiffCells bounds the table diffLines builds to about 8MB.  Longer changes are summarized instead
+const maxDiffCells = 1 << 20
+
+// diffLines returns the lines removed from expected prefixed with "-" and the lines added in actual prefixed with "+",
+// with unchanged lines prefixed by a space
+func diffLines(expected, actual string) string {
+	a, b := strings.Split(expected, "\n"), strings.Split(actual, "\n")
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	diff := &strings.Builder{}
+	for _, line := range a[:prefix] {
+		fmt.Fprintf(diff, "  %s\n", line)
+	}
+	removed, added := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(removed)+1)*(len(added)+1) > maxDiffCells {
+		summarizeChange(diff, removed, added)
+	} else {
+		diffChange(diff, removed, added)
+	}
+	for _, line := range a[len(a)-suffix:] {
+		fmt.Fprintf(diff, "  %s\n", line)
+	}
+	return diff.String()
+}
+
+// diffChange writes the shortest diff between a and b, which have no common first or last line
+func diffChange(diff *strings.Builder, a, b []string) {
+	common := make([][]int, len(a)+1) // common[i][j] is the longest common subsequence of a[i:] and b[j:]
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			fmt.Fprintf(diff, "  %s\n", a[i])
+			i, j = i+1, j+1
+		case j < len(b) && (i == len(a) || common[i][j+1] >= common[i+1][j]):
+			fmt.Fprintf(diff, "+ %s\n", b[j])
+			j++
+		default:
+			fmt.Fprintf(diff, "- %s\n", a[i])
+			i++
+		}
+	}
+}
+
+// summarizeChange writes only the first differing lines of a change too long for diffChange
+func summarizeChange(diff *strings.Builder, a, b []string) {
+	if len(a) > 0 {
+		fmt.Fprintf(diff, "- %s\n", a[0])
+	}
+	if len(b) > 0 {
+		fmt.Fprintf(diff, "+ %s\n", b[0])
+	}
+	fmt.Fprintf(diff, "... %d lines replaced by %d lines, too many to diff\n", len(a), len(b))
+}
@@ -0,0 +1,70 @@
+package oneweb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type SnapshotController struct {
+	greeting string
+}
+
+func (c *SnapshotController) Get(cr *ControllerRequest) (string, error) {
+	return `{"greeting":"` + c.greeting + `","id":"` + cr.ItemID + `"}`, nil
+}
+
+func (c *SnapshotController) GetText(cr *ControllerRequest) (string, error) {
+	return "plain text", nil
+}
+
+func (c *SnapshotController) GetBogus(id string) (string, error) {
+	return "", nil
+}
+
+var snapshotInputs = FuzzOptions{Users: []*User{{}, {UserID: 1}}, ItemIDs: []string{"1"}}
+
+func TestSnapshotTestController(t *testing.T) {
+	SnapshotTestController(t, "greeting", &SnapshotController{"hello"}, SnapshotOptions{Inputs: snapshotInputs})
+}
+
+func TestSnapshotTestControllerDiff(t *testing.T) {
+	dir := t.TempDir()
+	SnapshotTestController(t, "greeting", &SnapshotController{"hello"}, SnapshotOptions{Dir: dir, Update: true})
+	if _, err := ioutil.ReadFile(filepath.Join(dir, "greeting", "GetText.json")); err != nil {
+		t.Fatal("expected snapshot to be written", err)
+	}
+	tester := &MockTestRunner{}
+	SnapshotTestController(tester, "greeting", &SnapshotController{"goodbye"}, SnapshotOptions{Dir: dir})
+	if len(tester.Errors) != 1 || !strings.Contains(tester.Errors[0], `-       "greeting": "hello",`) || !strings.Contains(tester.Errors[0], `+       "greeting": "goodbye",`) {
+		t.Fatal("expected diff of the changed response", tester.Errors)
+	}
+}
+
+func TestSnapshotTestControllerMissing(t *testing.T) {
+	tester := &MockTestRunner{}
+	SnapshotTestController(tester, "missing", &SnapshotController{}, SnapshotOptions{Dir: t.TempDir()})
+	if len(tester.Errors) != 2 || !strings.Contains(tester.Errors[0], "Run with UPDATE_SNAPSHOTS=1 to create it") {
+		t.Fatal("expected missing snapshot errors", tester.Errors)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	if diff := diffLines("a\nb\nc", "a\nc\nd"); diff != "  a\n- b\n  c\n+ d\n" {
+		t.Fatal("unexpected diff", diff)
+	}
+}
+
+func TestDiffLinesLongChange(t *testing.T) {
+	var expected, actual []string
+	for i := 0; i < 2000; i++ {
+		expected = append(expected, fmt.Sprintf("old %d", i))
+		actual = append(actual, fmt.Sprintf("new %d", i))
+	}
+	diff := diffLines("start\n"+strings.Join(expected, "\n")+"\nend", "start\n"+strings.Join(actual, "\n")+"\nend")
+	if diff != "  start\n- old 0\n+ new 0\n... 2000 lines replaced by 2000 lines, too many to diff\n  end\n" {
+		t.Fatal("expected long change to be summarized", diff)
+	}
+}
@@ -0,0 +1,28 @@
+package oneweb
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusWriter(t *testing.T) {
+	rw := httptest.NewRecorder()
+	sw := &statusWriter{ResponseWriter: rw}
+	if sw.Status() != 200 {
+		t.Fatal("expected default status of 200")
+	}
+	sw.WriteHeader(404)
+	sw.WriteHeader(500)
+	sw.Write([]byte("hello"))
+	sw.Flush()
+	if sw.Status() != 404 || sw.bytes != 5 || !rw.Flushed || sw.Unwrap() != rw {
+		t.Fatal("expected first status and byte count to be recorded", sw.status, sw.bytes)
+	}
+}
+
+func TestStatusWriterHijackUnsupported(t *testing.T) {
+	sw := &statusWriter{ResponseWriter: httptest.NewRecorder()}
+	if _, _, err := sw.Hijack(); err == nil {
+		t.Fatal("expected hijack to fail for a recorder")
+	}
+}
@@ -0,0 +1,119 @@
+package oneweb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultStreamFlushItems    = 100
+	defaultStreamFlushInterval = time.Second
+)
+
+// ResponseStream is passed to Get and Index methods with the signature
+// (cr *ControllerRequest, stream *ResponseStream) error.  Items are written as they are encoded, as a JSON
+// array or, for clients which accept application/x-ndjson, as newline delimited JSON.  Encode returns an
+// error once the client has gone away, and methods should stop producing items when it does
+type ResponseStream struct {
+	FlushItems    int           // flush after this many items have been buffered
+	FlushInterval time.Duration // flush when an item is encoded this long after the last flush
+	rw            http.ResponseWriter
+	ctx           context.Context
+	ndjson        bool
+	started       bool
+	count         int
+	unflushed     int
+	lastFlush     time.Time
+	err           error
+}
+
+func newResponseStream(ctx context.Context, rw http.ResponseWriter, ndjson bool) *ResponseStream {
+	return &ResponseStream{FlushItems: defaultStreamFlushItems, FlushInterval: defaultStreamFlushInterval, rw: rw, ctx: ctx, ndjson: ndjson, lastFlush: time.Now()}
+}
+
+func (s *ResponseStream) Encode(item interface{}) error {
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	s.start()
+	if s.ndjson {
+		data = append(data, '\n')
+	} else if s.count > 0 {
+		s.write([]byte{','})
+	}
+	s.write(data)
+	s.count++
+	s.unflushed++
+	if s.unflushed >= s.FlushItems || time.Since(s.lastFlush) >= s.FlushInterval {
+		s.Flush()
+	}
+	return s.err
+}
+
+// Flush sends the items encoded so far to the client
+func (s *ResponseStream) Flush() {
+	if flusher, ok := s.rw.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	s.unflushed = 0
+	s.lastFlush = time.Now()
+}
+
+// Count returns the number of items encoded so far
+func (s *ResponseStream) Count() int {
+	return s.count
+}
+
+func (s *ResponseStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.rw.Header().Add("Access-Control-Allow-Origin", "*")
+	if s.ndjson {
+		s.rw.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		s.rw.Header().Set("Content-Type", "application/json")
+		s.write([]byte{'['})
+	}
+}
+
+func (s *ResponseStream) write(p []byte) {
+	if s.err == nil {
+		_, s.err = s.rw.Write(p)
+	}
+}
+
+func (s *ResponseStream) close() error {
+	s.start()
+	if !s.ndjson {
+		s.write([]byte{']'})
+	}
+	return s.err
+}
+
+// callStreamMethod runs a streaming method.  If the method fails after items have been written the JSON
+// array is left unterminated, so clients can tell the response is incomplete
+func callStreamMethod(cr *ControllerRequest, method *route, rw http.ResponseWriter, r *http.Request) error {
+	stream := newResponseStream(cr.Context(), rw, acceptsNDJSON(r))
+	if err := method.stream(cr, stream); err != nil {
+		return errors.Wrap(err, "Internal error calling controller method")
+	}
+	return stream.close()
+}
+
+func acceptsNDJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+}
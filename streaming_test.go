@@ -0,0 +1,123 @@
+package oneweb
+
+import (
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+type StreamController struct {
+}
+
+func (c *StreamController) GetExport(cr *ControllerRequest, stream *ResponseStream) error {
+	count, _ := strconv.Atoi(cr.ActionFilter)
+	for i := 0; i < count; i++ {
+		if err := stream.Encode(map[string]int{"n": i}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *StreamController) GetBroken(cr *ControllerRequest, stream *ResponseStream) error {
+	if cr.ActionFilter == "late" {
+		stream.Encode(1)
+	}
+	return errors.New("database went away")
+}
+
+func (c *StreamController) PutStream(cr *ControllerRequest, stream *ResponseStream) error {
+	return nil
+}
+
+func getStreamRouter() *ControllerRoutingHandler {
+	router := getMockRouter()
+	router.RegisterController("streams", &StreamController{})
+	return router
+}
+
+func TestStreamJSONArray(t *testing.T) {
+	for filter, expected := range map[string]string{"3": `[{"n":0},{"n":1},{"n":2}]`, "0": `[]`} {
+		rw := httptest.NewRecorder()
+		getStreamRouter().controllerRoutingHandler(rw, newHttpRequest("GET", "/streams/1/export/"+filter, nil))
+		if rw.Code != 200 || rw.Body.String() != expected || rw.Header().Get("Content-Type") != "application/json" {
+			t.Error("unexpected streamed JSON array", filter, rw.Code, rw.Body.String())
+		}
+	}
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	r := newHttpRequest("GET", "/streams/1/export/2", nil)
+	r.Header.Set("Accept", "application/x-ndjson")
+	rw := httptest.NewRecorder()
+	getStreamRouter().controllerRoutingHandler(rw, r)
+	if rw.Body.String() != "{\"n\":0}\n{\"n\":1}\n" || rw.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatal("unexpected NDJSON stream", rw.Body.String(), rw.Header())
+	}
+}
+
+func TestStreamErrorBeforeItems(t *testing.T) {
+	rw := httptest.NewRecorder()
+	getStreamRouter().controllerRoutingHandler(rw, newHttpRequest("GET", "/streams/1/broken", nil))
+	if rw.Code != 500 || getErrorMessage(rw) != "Internal error calling controller method: database went away" {
+		t.Fatal("expected error response", rw.Code, rw.Body.String())
+	}
+}
+
+func TestStreamErrorAfterItems(t *testing.T) {
+	logger := &MockLogger{}
+	router := getStreamRouter()
+	router.Logger = logger
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, newHttpRequest("GET", "/streams/1/broken/late", nil))
+	if rw.Code != 200 || rw.Body.String() != "[1" || logger.Entries[0].arg("error") != "Internal error calling controller method: database went away" {
+		t.Fatal("expected unterminated array and logged error", rw.Code, rw.Body.String(), logger.Entries)
+	}
+}
+
+func TestStreamFlushes(t *testing.T) {
+	rw := httptest.NewRecorder()
+	stream := newResponseStream(context.Background(), rw, false)
+	stream.FlushItems = 2
+	stream.Encode(1)
+	if rw.Flushed {
+		t.Fatal("expected no flush before FlushItems")
+	}
+	stream.Encode(2)
+	if !rw.Flushed || stream.Count() != 2 || stream.unflushed != 0 {
+		t.Fatal("expected flush after FlushItems")
+	}
+}
+
+func TestStreamStopsWhenClientDisconnects(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newResponseStream(ctx, httptest.NewRecorder(), false)
+	cancel()
+	if err := stream.Encode(1); err != context.Canceled || stream.Count() != 0 {
+		t.Fatal("expected Encode to fail once the client has gone away", err)
+	}
+}
+
+func TestStreamEncodeError(t *testing.T) {
+	stream := newResponseStream(context.Background(), httptest.NewRecorder(), false)
+	if err := stream.Encode(make(chan int)); err == nil || stream.started {
+		t.Fatal("expected unencodable item to fail without starting the response")
+	}
+}
+
+func TestValidateStreamMethod(t *testing.T) {
+	err := NewControllerRoutingHandler().RegisterController("streams", &StreamController{})
+	if err.Error() != "Method \"PutStream\" error: Only Get and Index methods can stream responses\n" {
+		t.Fatal("expected only PutStream to be rejected", err)
+	}
+}
+
+func TestFuzzTestControllerStreamMethod(t *testing.T) {
+	result := fuzzTestControllerMethod(&StreamController{}, "GetExport")
+	if result.ValidationError != nil || result.ReturnData[0] != "[]" || result.ReturnData[1] != nil {
+		t.Fatal("expected empty streamed array", result)
+	}
+}
@@ -0,0 +1,162 @@
+package oneweb
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracer starts spans.  Implementations should parent new spans on the span in ctx, or on the remote
+// span from SpanContextFromContext when ctx holds no local span.  Adapting an OpenTelemetry tracer only
+// requires wrapping its Start method and span type
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// SpanContext identifies a span across process boundaries as described by the W3C Trace Context spec
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	TraceFlags byte
+	TraceState string
+}
+
+func (s SpanContext) IsValid() bool {
+	return s.TraceID != [16]byte{} && s.SpanID != [8]byte{}
+}
+
+// TraceParent formats the span context as a W3C traceparent header value
+func (s SpanContext) TraceParent() string {
+	return "00-" + hex.EncodeToString(s.TraceID[:]) + "-" + hex.EncodeToString(s.SpanID[:]) + "-" + hex.EncodeToString([]byte{s.TraceFlags})
+}
+
+type spanContextKey struct{}
+
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context propagated from the incoming traceparent header, if any
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc, ok
+}
+
+func parseTraceParent(traceParent, traceState string) (SpanContext, bool) {
+	sc := SpanContext{TraceState: traceState}
+	version, flags := []byte{0}, []byte{0}
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || !decodeHex(version, parts[0]) || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) ||
+		!decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) || !decodeHex(flags, parts[3]) || !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.TraceFlags = flags[0]
+	return sc, true
+}
+
+func decodeHex(dst []byte, value string) bool {
+	if len(value) != hex.EncodedLen(len(dst)) || strings.ToLower(value) != value {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(value))
+	return err == nil
+}
+
+type noopTracer struct{}
+
+type noopSpan struct{}
+
+func (noopTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+
+func (noopSpan) RecordError(err error) {}
+
+func (noopSpan) End() {}
+
+func (c *ControllerRoutingHandler) tracer() Tracer {
+	if c.Tracer == nil {
+		return noopTracer{}
+	}
+	return c.Tracer
+}
+
+// startSpan starts a child span of the request span held in cr's context
+func (c *ControllerRoutingHandler) startSpan(cr *ControllerRequest, name string) Span {
+	_, span := c.tracer().Start(cr.Context(), name)
+	return span
+}
+
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// InMemoryTracer records finished spans so tests can assert on them
+type InMemoryTracer struct {
+	lock  sync.Mutex
+	spans []*RecordedSpan
+}
+
+type RecordedSpan struct {
+	Name       string
+	Context    SpanContext
+	Parent     SpanContext
+	Attributes map[string]interface{}
+	Errors     []error
+	StartTime  time.Time
+	EndTime    time.Time
+	tracer     *InMemoryTracer
+}
+
+type recordedSpanKey struct{}
+
+func (t *InMemoryTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	span := &RecordedSpan{Name: name, Attributes: make(map[string]interface{}), StartTime: time.Now(), tracer: t}
+	if parent, ok := ctx.Value(recordedSpanKey{}).(*RecordedSpan); ok {
+		span.Parent = parent.Context
+	} else if remote, ok := SpanContextFromContext(ctx); ok {
+		span.Parent = remote
+	}
+	span.Context = span.Parent
+	if !span.Parent.IsValid() {
+		rand.Read(span.Context.TraceID[:])
+	}
+	rand.Read(span.Context.SpanID[:])
+	return context.WithValue(ctx, recordedSpanKey{}, span), span
+}
+
+// Spans returns the spans which have ended, in the order they ended
+func (t *InMemoryTracer) Spans() []*RecordedSpan {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return append([]*RecordedSpan(nil), t.spans...)
+}
+
+func (s *RecordedSpan) SetAttribute(key string, value interface{}) {
+	s.Attributes[key] = value
+}
+
+func (s *RecordedSpan) RecordError(err error) {
+	s.Errors = append(s.Errors, err)
+}
+
+func (s *RecordedSpan) End() {
+	s.EndTime = time.Now()
+	s.tracer.lock.Lock()
+	defer s.tracer.lock.Unlock()
+	s.tracer.spans = append(s.tracer.spans, s)
+}
@@ -0,0 +1,99 @@
+package oneweb
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceParent(t *testing.T) {
+	sc, ok := parseTraceParent(testTraceParent, "vendor=value")
+	if !ok || sc.TraceParent() != testTraceParent || sc.TraceFlags != 1 || sc.TraceState != "vendor=value" {
+		t.Fatal("expected valid span context", sc)
+	}
+	if _, ok := parseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", ""); !ok {
+		t.Error("expected future versions with extra fields to be accepted")
+	}
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	} {
+		if _, ok := parseTraceParent(invalid, ""); ok {
+			t.Error("expected invalid traceparent", invalid)
+		}
+	}
+}
+
+func TestTracingSpans(t *testing.T) {
+	tracer := &InMemoryTracer{}
+	router := getMockRouter()
+	router.Tracer = tracer
+	r := newHttpRequest("GET", "/projects/123/method", nil)
+	r.Header.Set("traceparent", testTraceParent)
+	router.controllerRoutingHandler(httptest.NewRecorder(), r)
+
+	spans := tracer.Spans()
+	if len(spans) != 4 || spans[0].Name != "decode" || spans[1].Name != "invoke" || spans[2].Name != "encode" || spans[3].Name != "Projects.GetMethod" {
+		t.Fatal("expected decode, invoke and encode spans inside the request span", spans)
+	}
+	request := spans[3]
+	if request.Parent.TraceParent() != testTraceParent || request.Context.TraceID != request.Parent.TraceID || request.Attributes["http.status_code"] != 200 {
+		t.Fatal("expected request span to continue the incoming trace", request)
+	}
+	for _, child := range spans[:3] {
+		if child.Parent != request.Context {
+			t.Error("expected child span of the request span", child)
+		}
+	}
+}
+
+func TestTracingRecordsErrors(t *testing.T) {
+	tracer := &InMemoryTracer{}
+	router := getMockRouter()
+	router.Tracer = tracer
+	router.controllerRoutingHandler(httptest.NewRecorder(), newHttpRequest("GET", "/projects/1/error", nil))
+
+	spans := tracer.Spans()
+	if len(spans) != 3 || spans[1].Name != "invoke" || len(spans[1].Errors) != 1 || spans[1].Errors[0].Error() != "failed" {
+		t.Fatal("expected error on invoke span", spans)
+	}
+	if request := spans[2]; request.Parent.IsValid() || !request.Context.IsValid() || len(request.Errors) != 1 || request.Attributes["http.status_code"] != 500 {
+		t.Fatal("expected new trace with error on request span", request)
+	}
+}
+
+func TestTracingRawMethod(t *testing.T) {
+	tracer := &InMemoryTracer{}
+	router := getMockRouter()
+	router.Tracer = tracer
+	router.controllerRoutingHandler(httptest.NewRecorder(), newHttpRequest("GET", "/projects/123/Rawmethod", nil))
+	if spans := tracer.Spans(); len(spans) != 2 || spans[0].Name != "invoke" || spans[1].Name != "Projects.GetRawmethod" {
+		t.Fatal("expected invoke and request spans", spans)
+	}
+}
+
+func TestStartRequestSpanSetsContext(t *testing.T) {
+	router := NewControllerRoutingHandler()
+	router.Tracer = &InMemoryTracer{}
+	r := newHttpRequest("GET", "/projects", nil)
+	cr := newControllerRequest(r, LegacyNaming)
+	router.startRequestSpan(r, cr, "Projects.Index")
+	if _, ok := cr.Context().Value(recordedSpanKey{}).(*RecordedSpan); !ok {
+		t.Fatal("expected request span in ControllerRequest context")
+	}
+}
+
+func TestControllerRequestContextDefault(t *testing.T) {
+	if (&ControllerRequest{}).Context() != context.Background() {
+		t.Fatal("expected background context")
+	}
+}
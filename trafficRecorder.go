@@ -0,0 +1,276 @@
+package oneweb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultMaxRecordedBody = 64 * 1024
+
+// DefaultRedactFields are JSON body fields replaced with [REDACTED] in recorded traffic
+var DefaultRedactFields = []string{"password", "token", "secret"}
+
+// TrafficRecord is one request and its response as written by a TrafficRecorder, one JSON object per line
+type TrafficRecord struct {
+	Time            time.Time         `json:"time"`
+	Method          string            `json:"method"`
+	URL             string            `json:"url"`
+	RequestID       string            `json:"requestId"` // sent again on replay so that error bodies match
+	RequestHeaders  map[string]string `json:"requestHeaders,omitempty"`
+	RequestBody     string            `json:"requestBody,omitempty"`
+	Status          int               `json:"status"`
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
+	ResponseBody    string            `json:"responseBody,omitempty"`
+	Truncated       bool              `json:"truncated,omitempty"` // a body was longer than MaxBodySize
+}
+
+// TrafficRecorder writes sanitized requests and responses as JSONL.  Redacted request and response headers, JSON
+// fields and query parameters are replaced with [REDACTED], and X-User is reduced to the UserID so that replayed
+// requests run as the same user
+type TrafficRecorder struct {
+	RedactHeaders []string
+	RedactFields  []string
+	MaxBodySize   int // bodies are cut off after this many bytes, 64KB if not set
+	lock          sync.Mutex
+	encoder       *json.Encoder
+}
+
+func NewTrafficRecorder(w io.Writer) *TrafficRecorder {
+	return &TrafficRecorder{RedactHeaders: []string{"Authorization", "Cookie", "Set-Cookie"}, RedactFields: DefaultRedactFields, encoder: json.NewEncoder(w)}
+}
+
+// trafficWriter keeps a copy of the response as sent, before it is decompressed for the record
+type trafficWriter struct {
+	http.ResponseWriter
+	recorder    *TrafficRecorder
+	r           *http.Request
+	start       time.Time
+	requestBody *limitedBuffer
+	body        limitedBuffer
+}
+
+// start wraps rw and r.Body to capture the request and response.  It returns rw unchanged when recording is disabled
+func (t *TrafficRecorder) start(rw http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	if t == nil {
+		return rw
+	}
+	w := &trafficWriter{ResponseWriter: rw, recorder: t, r: r, start: time.Now(), requestBody: &limitedBuffer{max: t.maxBodySize()}}
+	w.body.max = t.maxBodySize()
+	if r.Body != nil {
+		r.Body = teeReadCloser{io.TeeReader(r.Body, w.requestBody), r.Body}
+	}
+	return w
+}
+
+func (t *TrafficRecorder) maxBodySize() int {
+	if t.MaxBodySize <= 0 {
+		return defaultMaxRecordedBody
+	}
+	return t.MaxBodySize
+}
+
+// finish writes the record for a response started by start
+func (t *TrafficRecorder) finish(rw http.ResponseWriter, cr *ControllerRequest, status int) {
+	w, ok := rw.(*trafficWriter)
+	if !ok {
+		return
+	}
+	record := TrafficRecord{
+		Time:            w.start.UTC(),
+		Method:          w.r.Method,
+		URL:             t.sanitizeURL(w.r.URL),
+		RequestID:       cr.RequestID,
+		RequestHeaders:  t.sanitizeHeaders(w.r.Header, cr),
+		RequestBody:     t.sanitizeBody(w.requestBody.Bytes()),
+		Status:          status,
+		ResponseHeaders: make(map[string]string),
+		Truncated:       w.requestBody.truncated || w.body.truncated,
+	}
+	header := w.Header()
+	body, err := decodeBody(header.Get("Content-Encoding"), w.body.Bytes())
+	if err != nil && !w.body.truncated { // a truncated body can't be decompressed to the end
+		body = w.body.Bytes()
+		record.ResponseHeaders["Content-Encoding"] = header.Get("Content-Encoding")
+	}
+	record.ResponseBody = t.sanitizeBody(body)
+	for name, values := range header {
+		switch {
+		case unreplayedHeaders[name]:
+		case containsHeader(t.RedactHeaders, name):
+			record.ResponseHeaders[name] = "[REDACTED]"
+		default:
+			record.ResponseHeaders[name] = strings.Join(values, ", ")
+		}
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.encoder.Encode(record)
+}
+
+func (t *TrafficRecorder) sanitizeHeaders(header http.Header, cr *ControllerRequest) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		switch {
+		case name == "X-User":
+			headers[name] = `{"UserID":` + strconv.Itoa(cr.User.UserID) + `}`
+		case name == "Content-Length":
+		case containsHeader(t.RedactHeaders, name):
+			headers[name] = "[REDACTED]"
+		default:
+			headers[name] = strings.Join(values, ", ")
+		}
+	}
+	return headers
+}
+
+// sanitizeURL redacts query parameters named like RedactFields
+func (t *TrafficRecorder) sanitizeURL(u *url.URL) string {
+	query := u.Query()
+	redacted := false
+	for name := range query {
+		if containsFold(t.RedactFields, name) {
+			query[name] = []string{"[REDACTED]"}
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.RequestURI()
+	}
+	sanitized := *u
+	sanitized.RawQuery = query.Encode()
+	return sanitized.RequestURI()
+}
+
+func containsHeader(names []string, name string) bool {
+	for _, redacted := range names {
+		if http.CanonicalHeaderKey(redacted) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeBody redacts fields of JSON bodies.  Other bodies are recorded as is
+func (t *TrafficRecorder) sanitizeBody(body []byte) string {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if len(t.RedactFields) == 0 || decoder.Decode(&value) != nil || decoder.More() {
+		return string(body)
+	}
+	if !t.redactFields(value) {
+		return string(body)
+	}
+	redacted, _ := json.Marshal(value)
+	return string(redacted)
+}
+
+// redactFields replaces matching object fields at any depth, reporting whether anything was replaced
+func (t *TrafficRecorder) redactFields(value interface{}) bool {
+	redacted := false
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for name, field := range value {
+			if containsFold(t.RedactFields, name) {
+				value[name] = "[REDACTED]"
+				redacted = true
+			} else if t.redactFields(field) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, item := range value {
+			if t.redactFields(item) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
+
+func containsFold(values []string, value string) bool {
+	for _, item := range values {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeBody(contentEncoding string, body []byte) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch strings.ToLower(contentEncoding) {
+	case "":
+		return body, nil
+	case "gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return nil, errors.New("Unsupported Content-Encoding")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+func (w *trafficWriter) Write(p []byte) (int, error) {
+	w.body.Write(p)
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *trafficWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *trafficWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("ResponseWriter does not support hijacking")
+}
+
+func (w *trafficWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// limitedBuffer keeps the first max bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.Buffer.Write(p[:room])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
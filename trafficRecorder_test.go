@@ -0,0 +1,109 @@
+package oneweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type SecretController struct {
+}
+
+func (c *SecretController) Put(cr *ControllerRequest, data *SimpleData) (string, error) {
+	return `{"hello":"` + data.Hello + `","token":"abc","nested":[{"Secret":"x"}]}`, nil
+}
+
+func (c *SecretController) Post(cr *ControllerRequest, rw http.ResponseWriter, r *http.Request) {
+	http.SetCookie(rw, &http.Cookie{Name: "session", Value: "SECRETSESSION"})
+	rw.Write([]byte("{}"))
+}
+
+func recordRequest(router *ControllerRoutingHandler, method, url, body string, headers map[string]string) {
+	r := newHttpRequest(method, url, ioutil.NopCloser(bytes.NewBufferString(body)))
+	r.Header.Set("X-User", `{"UserID":5,"Email":"private@example.com"}`)
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	router.controllerRoutingHandler(httptest.NewRecorder(), r)
+}
+
+func readTrafficRecords(t *testing.T, jsonl *bytes.Buffer) []TrafficRecord {
+	var records []TrafficRecord
+	decoder := json.NewDecoder(jsonl)
+	for decoder.More() {
+		var record TrafficRecord
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestTrafficRecorder(t *testing.T) {
+	jsonl := &bytes.Buffer{}
+	router := getMockRouter()
+	router.RegisterController("secrets", &SecretController{})
+	router.Recorder = NewTrafficRecorder(jsonl)
+	recordRequest(router, "PUT", "/secrets/1?a=b", `{"Hello":"world","Password":"hunter2"}`, map[string]string{"Authorization": "Bearer abc"})
+	recordRequest(router, "GET", "/projects/1/error", "", nil)
+
+	records := readTrafficRecords(t, jsonl)
+	if len(records) != 2 {
+		t.Fatal("expected a record per request", records)
+	}
+	put := records[0]
+	if put.Method != "PUT" || put.URL != "/secrets/1?a=b" || put.Status != 200 || put.RequestID == "" ||
+		put.RequestHeaders["Authorization"] != "[REDACTED]" || put.RequestHeaders["X-User"] != `{"UserID":5}` ||
+		put.RequestBody != `{"Hello":"world","Password":"[REDACTED]"}` ||
+		put.ResponseBody != `{"hello":"world","nested":[{"Secret":"[REDACTED]"}],"token":"[REDACTED]"}` ||
+		put.ResponseHeaders["Content-Type"] != "application/json" || put.ResponseHeaders[RequestIDHeader] != "" {
+		t.Error("unexpected sanitized record", put)
+	}
+	if failed := records[1]; failed.Status != 500 || !strings.Contains(failed.ResponseBody, `"error":"Internal error calling controller method: failed"`) {
+		t.Error("expected error response to be recorded", failed)
+	}
+}
+
+func TestTrafficRecorderDecompresses(t *testing.T) {
+	jsonl := &bytes.Buffer{}
+	router := getMockRouter()
+	router.Compression = NewCompression(0)
+	router.Recorder = NewTrafficRecorder(jsonl)
+	recordRequest(router, "GET", "/projects/123/method", "", map[string]string{"Accept-Encoding": "gzip"})
+	records := readTrafficRecords(t, jsonl)
+	if len(records) != 1 || records[0].ResponseBody != "called GetMethod" || records[0].ResponseHeaders["Content-Encoding"] != "" {
+		t.Fatal("expected decompressed response body", records)
+	}
+}
+
+func TestTrafficRecorderTruncates(t *testing.T) {
+	jsonl := &bytes.Buffer{}
+	router := getMockRouter()
+	router.Recorder = NewTrafficRecorder(jsonl)
+	router.Recorder.MaxBodySize = 6
+	recordRequest(router, "GET", "/projects/123/method", "", nil)
+	records := readTrafficRecords(t, jsonl)
+	if len(records) != 1 || records[0].ResponseBody != "called" || !records[0].Truncated {
+		t.Fatal("expected truncated response body", records)
+	}
+}
+
+func TestTrafficRecorderRedactsResponseHeadersAndQuery(t *testing.T) {
+	jsonl := &bytes.Buffer{}
+	router := getMockRouter()
+	router.RegisterController("secrets", &SecretController{})
+	router.Recorder = NewTrafficRecorder(jsonl)
+	recordRequest(router, "POST", "/secrets?token=abc&page=2", "", nil)
+	records := readTrafficRecords(t, jsonl)
+	if len(records) != 1 || records[0].ResponseHeaders["Set-Cookie"] != "[REDACTED]" || strings.Contains(jsonl.String(), "SECRETSESSION") {
+		t.Fatal("expected response cookie to be redacted", records)
+	}
+	if records[0].URL != "/secrets?page=2&token=%5BREDACTED%5D" {
+		t.Error("expected query parameter to be redacted", records[0].URL)
+	}
+}
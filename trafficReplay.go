@@ -0,0 +1,185 @@
+package oneweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ReplayOptions configures ReplayTraffic
+type ReplayOptions struct {
+	IgnoreHeaders []string // response headers which are expected to change, such as Date
+	RedactFields  []string // the recorder's RedactFields, applied to replayed bodies before comparing.  DefaultRedactFields if nil
+}
+
+// ReplayDifference is a recorded request whose replayed response doesn't match the recording
+type ReplayDifference struct {
+	Line        int
+	Record      TrafficRecord
+	Differences []string
+}
+
+func (d ReplayDifference) String() string {
+	return fmt.Sprintf("Line %d: %s %s\n%s", d.Line, d.Record.Method, d.Record.URL, strings.Join(d.Differences, "\n"))
+}
+
+// AutoReplayTraffic replays a JSONL file written by a TrafficRecorder and reports each difference as an error
+func AutoReplayTraffic(t TestRunner, handler http.Handler, filename string, options ReplayOptions) {
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer file.Close()
+	differences, err := ReplayTraffic(handler, file, options)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, difference := range differences {
+		t.Error(difference.String())
+	}
+}
+
+// ReplayTraffic sends each recorded request to handler and compares the status, recorded response headers and body.
+// JSON bodies are compared by value.  Bodies of truncated records aren't compared.  Redacted response headers aren't
+// compared.  Redacted request headers aren't sent, but redacted request body fields and query parameters are sent as
+// the literal string [REDACTED], so routes which depend on their values should be recorded with them unredacted
+func ReplayTraffic(handler http.Handler, jsonl io.Reader, options ReplayOptions) ([]ReplayDifference, error) {
+	var differences []ReplayDifference
+	lines := &lineCounter{r: jsonl}
+	decoder := json.NewDecoder(lines)
+	for {
+		start := decoder.InputOffset()
+		var record TrafficRecord
+		err := decoder.Decode(&record)
+		if err == io.EOF {
+			return differences, nil
+		}
+		if err != nil {
+			offset := decoder.InputOffset() - 1 // the end of a record which was read but didn't match TrafficRecord
+			if decoder.InputOffset() == start { // the record isn't valid JSON, so find the line it starts on
+				buffered, _ := ioutil.ReadAll(decoder.Buffered())
+				offset = start + int64(len(buffered)-len(bytes.TrimLeft(buffered, " \t\r\n")))
+			}
+			return differences, errors.Wrapf(err, "Invalid traffic record on line %d", lines.line(offset))
+		}
+		if found := replayRecord(handler, record, options); len(found) != 0 {
+			differences = append(differences, ReplayDifference{lines.line(decoder.InputOffset() - 1), record, found})
+		}
+	}
+}
+
+// lineCounter remembers where the lines of a reader start, so that records read by a json.Decoder can be
+// reported by line.  Records may be any length, unlike with a bufio.Scanner
+type lineCounter struct {
+	r        io.Reader
+	offset   int64
+	newlines []int64
+}
+
+func (l *lineCounter) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			l.newlines = append(l.newlines, l.offset+int64(i))
+		}
+	}
+	l.offset += int64(n)
+	return n, err
+}
+
+// line returns the line number of the byte at offset, counting from 1
+func (l *lineCounter) line(offset int64) int {
+	return 1 + sort.Search(len(l.newlines), func(i int) bool { return l.newlines[i] >= offset })
+}
+
+func replayRecord(handler http.Handler, record TrafficRecord, options ReplayOptions) []string {
+	r := httptest.NewRequest(record.Method, record.URL, strings.NewReader(record.RequestBody))
+	for name, value := range record.RequestHeaders {
+		if value != "[REDACTED]" && name != "Accept-Encoding" { // recorded bodies are decompressed
+			r.Header.Set(name, value)
+		}
+	}
+	if record.RequestID != "" {
+		r.Header.Set(RequestIDHeader, record.RequestID)
+	}
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, r)
+
+	var differences []string
+	if rw.Code != record.Status {
+		differences = append(differences, fmt.Sprintf("status: recorded %d, replayed %d", record.Status, rw.Code))
+	}
+	names := make([]string, 0, len(record.ResponseHeaders))
+	for name := range record.ResponseHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if containsHeader(options.IgnoreHeaders, name) || record.ResponseHeaders[name] == "[REDACTED]" {
+			continue
+		}
+		recorded := record.ResponseHeaders[name]
+		if name == "Etag" {
+			recorded = unencodedETag(recorded, record.RequestHeaders["Accept-Encoding"])
+		}
+		if actual := strings.Join(rw.Header()[name], ", "); actual != recorded {
+			differences = append(differences, fmt.Sprintf("header %s: recorded %q, replayed %q", name, recorded, actual))
+		}
+	}
+	body := options.redactor().sanitizeBody(rw.Body.Bytes())
+	if !record.Truncated && !bodiesMatch(record.ResponseBody, body) {
+		differences = append(differences, "body:\n"+diffLines(indentJSON(record.ResponseBody), indentJSON(body)))
+	}
+	return differences
+}
+
+// unencodedETag removes the suffix setEncodedETag adds for a content-coding the recorded request accepted, since
+// requests are replayed without Accept-Encoding
+func unencodedETag(etag, acceptEncoding string) string {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		encoding := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		suffix := "-" + encoding + `"`
+		if encoding != "" && strings.HasSuffix(strings.ToLower(etag), suffix) {
+			return etag[:len(etag)-len(suffix)] + `"`
+		}
+	}
+	return etag
+}
+
+func (o ReplayOptions) redactor() *TrafficRecorder {
+	if o.RedactFields == nil {
+		return &TrafficRecorder{RedactFields: DefaultRedactFields}
+	}
+	return &TrafficRecorder{RedactFields: o.RedactFields}
+}
+
+func bodiesMatch(recorded, replayed string) bool {
+	if recorded == replayed {
+		return true
+	}
+	var a, b interface{}
+	if json.Unmarshal([]byte(recorded), &a) != nil || json.Unmarshal([]byte(replayed), &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// indentJSON spreads JSON over lines so that diffs point at the changed value
+func indentJSON(body string) string {
+	var indented bytes.Buffer
+	if json.Indent(&indented, []byte(body), "", "  ") != nil {
+		return body
+	}
+	return indented.String()
+}
@@ -0,0 +1,119 @@
+package oneweb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type VersionController struct {
+	version string
+}
+
+func (c *VersionController) Get(cr *ControllerRequest) (string, error) {
+	return `{"id":"` + cr.ItemID + `","version":"` + c.version + `"}`, nil
+}
+
+func getVersionRouter(version string, jsonl *bytes.Buffer) *ControllerRoutingHandler {
+	router := getMockRouter()
+	router.RegisterController("versions", &VersionController{version})
+	if jsonl != nil {
+		router.Recorder = NewTrafficRecorder(jsonl)
+	}
+	return router
+}
+
+func TestReplayTraffic(t *testing.T) {
+	jsonl := &bytes.Buffer{}
+	recording := getVersionRouter("1", jsonl)
+	recordRequest(recording, "GET", "/versions/7", "", nil)
+	recordRequest(recording, "GET", "/projects/1/error", "", nil)
+	recorded := jsonl.String()
+
+	if differences, err := ReplayTraffic(getVersionRouter("1", nil).Handler(), strings.NewReader(recorded), ReplayOptions{}); err != nil || len(differences) != 0 {
+		t.Fatal("expected identical responses", err, differences)
+	}
+	differences, err := ReplayTraffic(getVersionRouter("2", nil).Handler(), strings.NewReader(recorded), ReplayOptions{})
+	if err != nil || len(differences) != 1 || differences[0].Line != 1 {
+		t.Fatal("expected changed version to be reported", err, differences)
+	}
+	if diff := differences[0].String(); !strings.Contains(diff, `-   "version": "1"`) || !strings.Contains(diff, `+   "version": "2"`) {
+		t.Fatal("expected JSON diff", diff)
+	}
+}
+
+func TestReplayTrafficRedacted(t *testing.T) {
+	jsonl := &bytes.Buffer{}
+	recording := getMockRouter()
+	recording.RegisterController("secrets", &SecretController{})
+	recording.Recorder = NewTrafficRecorder(jsonl)
+	recordRequest(recording, "PUT", "/secrets/1", `{"Hello":"world"}`, nil)
+	recordRequest(recording, "POST", "/secrets", "", nil)
+
+	replaying := getMockRouter()
+	replaying.RegisterController("secrets", &SecretController{})
+	if differences, err := ReplayTraffic(replaying.Handler(), jsonl, ReplayOptions{}); err != nil || len(differences) != 0 {
+		t.Fatal("expected redacted fields and headers to match", err, differences)
+	}
+}
+
+func TestReplayTrafficCompressed(t *testing.T) {
+	jsonl := &bytes.Buffer{}
+	recording := getVersionRouter("1", jsonl)
+	recording.Compression = NewCompression(0)
+	recordRequest(recording, "GET", "/versions/7", "", map[string]string{"Accept-Encoding": "gzip"})
+	if !strings.Contains(jsonl.String(), `-gzip\"`) {
+		t.Fatal("expected the recorded ETag to be specific to gzip", jsonl.String())
+	}
+
+	replaying := getVersionRouter("1", nil)
+	replaying.Compression = NewCompression(0)
+	if differences, err := ReplayTraffic(replaying.Handler(), jsonl, ReplayOptions{}); err != nil || len(differences) != 0 {
+		t.Fatal("expected the uncompressed ETag to match", err, differences)
+	}
+}
+
+func TestUnencodedETag(t *testing.T) {
+	for etag, expected := range map[string]string{
+		`"abc-gzip"`:    `"abc"`,
+		`"abc-GZIP"`:    `"abc"`,
+		`"abc-deflate"`: `"abc-deflate"`,
+		`"v-1"`:         `"v-1"`,
+	} {
+		if actual := unencodedETag(etag, "br;q=1.0, gzip;q=0.5"); actual != expected {
+			t.Error("unexpected ETag", etag, actual)
+		}
+	}
+}
+
+func TestReplayTrafficStatusAndHeaders(t *testing.T) {
+	record := `{"method":"GET","url":"/versions/7","status":404,"responseHeaders":{"Content-Type":"text/plain","X-Ignored":"a"},"responseBody":"{\"id\":\"7\",\"version\":\"1\"}"}`
+	differences, _ := ReplayTraffic(getVersionRouter("1", nil).Handler(), strings.NewReader(record), ReplayOptions{IgnoreHeaders: []string{"x-ignored"}})
+	if len(differences) != 1 || strings.Join(differences[0].Differences, "\n") != "status: recorded 404, replayed 200\nheader Content-Type: recorded \"text/plain\", replayed \"application/json\"" {
+		t.Fatal("expected status and header differences", differences)
+	}
+}
+
+func TestAutoReplayTraffic(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "traffic.jsonl")
+	os.WriteFile(filename, []byte(`{"method":"GET","url":"/versions/7","status":200}`+"\nnot json\n"), 0644)
+	tester := &MockTestRunner{}
+	AutoReplayTraffic(tester, getVersionRouter("1", nil).Handler(), filename, ReplayOptions{})
+	if len(tester.Errors) != 2 || !strings.HasPrefix(tester.Errors[0], "Invalid traffic record on line 2") || !strings.HasPrefix(tester.Errors[1], "Line 1: GET /versions/7\nbody:") {
+		t.Fatal("expected parse error and body difference", tester.Errors)
+	}
+}
+
+func TestReplayTrafficLongRecords(t *testing.T) {
+	jsonl := &bytes.Buffer{}
+	recording := getVersionRouter(strings.Repeat("v", 2*1024*1024), jsonl)
+	recording.Recorder.MaxBodySize = 4 * 1024 * 1024
+	recordRequest(recording, "GET", "/versions/7", "", nil)
+	jsonl.WriteString("\n" + `{"method":"GET","url":"/versions/8","status":"200"}` + "\n")
+	differences, err := ReplayTraffic(getVersionRouter("1", nil).Handler(), jsonl, ReplayOptions{})
+	if len(differences) != 1 || differences[0].Line != 1 || err == nil || !strings.HasPrefix(err.Error(), "Invalid traffic record on line 3") {
+		t.Fatal("expected records of any length to be read", err, differences)
+	}
+}
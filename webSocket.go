@@ -0,0 +1,276 @@
+package oneweb
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// WebSocket message types
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	closeMessage  = 8
+	pingMessage   = 9
+	pongMessage   = 10
+)
+
+const (
+	webSocketGUID                = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultMaxWebSocketMessage   = 1 << 20
+	webSocketCloseNormal         = 1000
+	webSocketCloseProtocolError  = 1002
+	webSocketCloseMessageTooBig  = 1009
+	webSocketMaxControlFrameSize = 125
+	defaultWebSocketIdleTimeout  = time.Minute
+)
+
+// WebSocket is passed to Get and Index methods with the signature (cr *ControllerRequest, ws *WebSocket) error.
+// The router upgrades the connection before calling the method and closes it when the method returns.
+// Ping frames are answered automatically while ReadMessage is being called.  ReadMessage fails once the client
+// has sent nothing for the router's WebSocketIdleTimeout, so clients of quiet sockets should send pings
+type WebSocket struct {
+	MaxMessageSize int64
+	idleTimeout    time.Duration
+	conn           net.Conn
+	reader         *bufio.Reader
+	writeLock      sync.Mutex
+	closed         bool
+}
+
+func (c *ControllerRoutingHandler) upgradeWebSocket(rw http.ResponseWriter, r *http.Request) (*WebSocket, error) {
+	key := r.Header.Get("Sec-Websocket-Key")
+	if r.Method != "GET" || !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-Websocket-Version") != "13" || key == "" {
+		return nil, withStatus(http.StatusBadRequest, errors.New("Expected a version 13 WebSocket upgrade request"))
+	}
+	if !c.isWebSocketOriginAllowed(r) {
+		return nil, withStatus(http.StatusForbidden, errors.New("WebSocket origin not allowed"))
+	}
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("ResponseWriter does not support hijacking")
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + computeWebSocketAccept(key) + "\r\n\r\n")
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	ws := newWebSocket(conn, brw.Reader)
+	ws.idleTimeout = c.webSocketIdleTimeout()
+	return ws, nil
+}
+
+func newWebSocket(conn net.Conn, reader *bufio.Reader) *WebSocket {
+	return &WebSocket{MaxMessageSize: defaultMaxWebSocketMessage, conn: conn, reader: reader}
+}
+
+// isWebSocketOriginAllowed prevents cross-site WebSocket hijacking.  Browsers send the user's cookies with
+// WebSocket handshakes from any site, so only the server's own origin and WebSocketOrigins are accepted.
+// Requests without an Origin don't come from browsers and are allowed
+func (c *ControllerRoutingHandler) isWebSocketOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range c.WebSocketOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func (c *ControllerRoutingHandler) webSocketIdleTimeout() time.Duration {
+	if c.WebSocketIdleTimeout <= 0 {
+		return defaultWebSocketIdleTimeout
+	}
+	return c.WebSocketIdleTimeout
+}
+
+func computeWebSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ReadMessage returns the next text or binary message.  It returns io.EOF once the client closes the connection
+func (ws *WebSocket) ReadMessage() (int, []byte, error) {
+	var message []byte
+	messageType := 0
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case pingMessage:
+			ws.writeFrame(pongMessage, payload)
+			continue
+		case pongMessage:
+			continue
+		case closeMessage:
+			ws.closeWithCode(webSocketCloseNormal)
+			return 0, nil, io.EOF
+		case 0:
+			if messageType == 0 {
+				return 0, nil, ws.protocolError("continuation frame without a message")
+			}
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, ws.protocolError("new message before the previous one finished")
+			}
+			messageType = opcode
+		default:
+			return 0, nil, ws.protocolError("unknown opcode")
+		}
+		if int64(len(message)+len(payload)) > ws.MaxMessageSize {
+			ws.closeWithCode(webSocketCloseMessageTooBig)
+			return 0, nil, errors.New("WebSocket message too big")
+		}
+		message = append(message, payload...)
+		if fin {
+			return messageType, message, nil
+		}
+	}
+}
+
+func (ws *WebSocket) readFrame() (bool, int, []byte, error) {
+	if ws.idleTimeout > 0 {
+		ws.conn.SetReadDeadline(time.Now().Add(ws.idleTimeout))
+	}
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(ws.reader, header); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := int(header[0] & 0x0f)
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, ws.protocolError("reserved bits set")
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, ws.protocolError("client frames must be masked")
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(ws.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(ws.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended)
+	}
+	if opcode >= closeMessage && (length > webSocketMaxControlFrameSize || !fin) {
+		return false, 0, nil, ws.protocolError("invalid control frame")
+	}
+	if length > uint64(ws.MaxMessageSize) {
+		ws.closeWithCode(webSocketCloseMessageTooBig)
+		return false, 0, nil, errors.New("WebSocket message too big")
+	}
+
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(ws.reader, mask); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// WriteMessage sends a TextMessage or BinaryMessage.  It is safe to call concurrently with ReadMessage
+func (ws *WebSocket) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return errors.New("Unsupported WebSocket message type")
+	}
+	return ws.writeFrame(messageType, data)
+}
+
+func (ws *WebSocket) writeFrame(opcode int, payload []byte) error {
+	ws.writeLock.Lock()
+	defer ws.writeLock.Unlock()
+	if ws.closed {
+		return errors.New("WebSocket is closed")
+	}
+	frame := []byte{0x80 | byte(opcode)} // server frames are never fragmented or masked
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, byte(length))
+	case length <= 0xffff:
+		frame = append(frame, 126, byte(length>>8), byte(length))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(length))
+	}
+	_, err := ws.conn.Write(append(frame, payload...))
+	return err
+}
+
+// Close sends a normal closure frame and closes the connection
+func (ws *WebSocket) Close() error {
+	return ws.closeWithCode(webSocketCloseNormal)
+}
+
+func (ws *WebSocket) closeWithCode(code int) error {
+	ws.writeFrame(closeMessage, []byte{byte(code >> 8), byte(code)})
+	ws.writeLock.Lock()
+	defer ws.writeLock.Unlock()
+	if ws.closed {
+		return nil
+	}
+	ws.closed = true
+	return ws.conn.Close()
+}
+
+func (ws *WebSocket) protocolError(message string) error {
+	ws.closeWithCode(webSocketCloseProtocolError)
+	return errors.New("WebSocket protocol error: " + message)
+}
+
+func (c *ControllerRoutingHandler) callWebSocketMethod(cr *ControllerRequest, method *route, rw http.ResponseWriter, r *http.Request) error {
+	ws, err := c.upgradeWebSocket(rw, r)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+	if err := method.socket(cr, ws); err != nil {
+		return errors.Wrap(err, "Internal error calling controller method")
+	}
+	return nil
+}
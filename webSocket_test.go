@@ -0,0 +1,181 @@
+package oneweb
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type SocketController struct {
+}
+
+func (c *SocketController) GetEcho(cr *ControllerRequest, ws *WebSocket) error {
+	for {
+		messageType, data, err := ws.ReadMessage()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := ws.WriteMessage(messageType, data); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *SocketController) PutSocket(cr *ControllerRequest, ws *WebSocket) error {
+	return nil
+}
+
+func dialWebSocket(t *testing.T, url string) (net.Conn, *bufio.Reader) {
+	router := getMockRouter()
+	router.RegisterController("sockets", &SocketController{})
+	conn, reader, response, err := dialRouterWebSocket(t, router, url, "")
+	if err != nil || response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatal("expected WebSocket handshake", err, response)
+	}
+	return conn, reader
+}
+
+func dialRouterWebSocket(t *testing.T, router *ControllerRoutingHandler, url, origin string) (net.Conn, *bufio.Reader, *http.Response, error) {
+	server := httptest.NewServer(http.HandlerFunc(router.controllerRoutingHandler))
+	t.Cleanup(server.Close)
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	r, _ := http.NewRequest("GET", server.URL+url, nil)
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	r.Header.Set("X-User", `{"UserID":1}`)
+	if origin != "" {
+		r.Header.Set("Origin", strings.Replace(origin, "SERVER", server.Listener.Addr().String(), 1))
+	}
+	r.Write(conn)
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, r)
+	return conn, reader, response, err
+}
+
+func writeClientFrame(conn net.Conn, fin bool, opcode int, payload []byte) {
+	first := byte(opcode)
+	if fin {
+		first |= 0x80
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame := append([]byte{first, 0x80 | byte(len(payload))}, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	conn.Write(frame)
+}
+
+func readServerFrame(reader *bufio.Reader) (int, []byte) {
+	header := make([]byte, 2)
+	io.ReadFull(reader, header)
+	payload := make([]byte, header[1]&0x7f)
+	io.ReadFull(reader, payload)
+	return int(header[0] & 0x0f), payload
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	conn, reader := dialWebSocket(t, "/sockets/1/echo")
+	writeClientFrame(conn, false, TextMessage, []byte("hel"))
+	writeClientFrame(conn, true, pingMessage, []byte("ping"))
+	writeClientFrame(conn, true, 0, []byte("lo"))
+	if opcode, payload := readServerFrame(reader); opcode != pongMessage || string(payload) != "ping" {
+		t.Fatal("expected pong", opcode, string(payload))
+	}
+	if opcode, payload := readServerFrame(reader); opcode != TextMessage || string(payload) != "hello" {
+		t.Fatal("expected reassembled message to be echoed", opcode, string(payload))
+	}
+	writeClientFrame(conn, true, closeMessage, []byte{3, 232})
+	if opcode, payload := readServerFrame(reader); opcode != closeMessage || string(payload) != string([]byte{3, 232}) {
+		t.Fatal("expected close frame", opcode, payload)
+	}
+}
+
+func TestWebSocketRejectsUnmaskedFrames(t *testing.T) {
+	conn, reader := dialWebSocket(t, "/sockets/1/echo")
+	conn.Write([]byte{0x81, 1, 'a'})
+	if opcode, payload := readServerFrame(reader); opcode != closeMessage || string(payload) != string([]byte{3, 234}) {
+		t.Fatal("expected protocol error close frame", opcode, payload)
+	}
+}
+
+func TestWebSocketMessageTooBig(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	ws := newWebSocket(server, bufio.NewReader(server))
+	ws.MaxMessageSize = 2
+	go writeClientFrame(client, true, TextMessage, []byte("abc"))
+	go readServerFrame(bufio.NewReader(client))
+	if _, _, err := ws.ReadMessage(); err == nil || err.Error() != "WebSocket message too big" {
+		t.Fatal("expected message size limit", err)
+	}
+}
+
+func TestWebSocketBadHandshake(t *testing.T) {
+	router := getMockRouter()
+	router.RegisterController("sockets", &SocketController{})
+	rw := httptest.NewRecorder()
+	router.controllerRoutingHandler(rw, newHttpRequest("GET", "/sockets/1/echo", nil))
+	if rw.Code != 400 || getErrorMessage(rw) != "Expected a version 13 WebSocket upgrade request" {
+		t.Fatal("expected bad request for a plain GET", rw.Code, rw.Body.String())
+	}
+}
+
+func TestValidateWebSocketMethod(t *testing.T) {
+	err := NewControllerRoutingHandler().RegisterController("sockets", &SocketController{})
+	if err.Error() != "Method \"PutSocket\" error: Only Get and Index methods can stream responses\n" {
+		t.Fatal("expected only PutSocket to be rejected", err)
+	}
+}
+
+func TestFuzzTestControllerWebSocketMethod(t *testing.T) {
+	result := fuzzTestControllerMethod(&SocketController{}, "GetEcho")
+	if result.ValidationError != nil || result.ReturnData[0] != nil {
+		t.Fatal("expected method to see the client hang up", result)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	router := getMockRouter()
+	router.RegisterController("sockets", &SocketController{})
+	router.WebSocketOrigins = []string{"https://app.example.com"}
+	for origin, expected := range map[string]int{
+		"http://SERVER":            http.StatusSwitchingProtocols,
+		"https://app.example.com":  http.StatusSwitchingProtocols,
+		"https://evil.example.com": http.StatusForbidden,
+		"null":                     http.StatusForbidden,
+	} {
+		_, _, response, err := dialRouterWebSocket(t, router, "/sockets/1/echo", origin)
+		if err != nil || response.StatusCode != expected {
+			t.Error("unexpected handshake response for origin", origin, err, response)
+		}
+	}
+}
+
+func TestWebSocketIdleTimeout(t *testing.T) {
+	router := getMockRouter()
+	router.RegisterController("sockets", &SocketController{})
+	router.WebSocketIdleTimeout = 20 * time.Millisecond
+	conn, reader, response, err := dialRouterWebSocket(t, router, "/sockets/1/echo", "")
+	if err != nil || response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatal("expected WebSocket handshake", err, response)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if opcode, payload := readServerFrame(reader); opcode != closeMessage || string(payload) != string([]byte{3, 232}) {
+		t.Fatal("expected idle socket to be closed", opcode, payload)
+	}
+}